@@ -0,0 +1,154 @@
+package analyzer
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/glethuillier/deLLMiter/generator"
+)
+
+// alignmentLimit bounds the number of runes of a response aligned with the candidate,
+// the alignment being quadratic
+const alignmentLimit = 2000
+
+// itemSpan locates an item in the message of a candidate, in bytes
+type itemSpan struct {
+	item generator.Item
+	// index is the index of the item in the items of the candidate
+	index int
+	start int
+	end   int
+}
+
+// locateItems finds the items of the candidate in its message, in the form they take in the message, in order.
+// Items not found are skipped.
+func locateItems(candidate generator.Candidate) []itemSpan {
+	var spans []itemSpan
+	position := 0
+	for i, item := range candidate.Items {
+		form := item.Form()
+		if form == "" {
+			continue
+		}
+		index := strings.Index(candidate.Message[position:], form)
+		if index == -1 {
+			continue
+		}
+		start := position + index
+		spans = append(spans, itemSpan{item: item, index: i, start: start, end: start + len(form)})
+		position = start + len(form)
+	}
+	return spans
+}
+
+// alignment matches the runes of a candidate message with the runes of a response,
+// following their longest common subsequence
+type alignment struct {
+	message  []rune
+	response []rune
+	// matches holds, for each rune of the message, the index of the matching rune of the response, or -1
+	matches []int
+}
+
+func align(message, response string) *alignment {
+	a := &alignment{message: []rune(message), response: []rune(response)}
+	if len(a.response) > alignmentLimit {
+		a.response = a.response[:alignmentLimit]
+	}
+
+	n, m := len(a.message), len(a.response)
+	// lcs[i*(m+1)+j] is the length of the longest common subsequence of message[i:] and response[j:]
+	lcs := make([]int32, (n+1)*(m+1))
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a.message[i] == a.response[j] {
+				lcs[i*(m+1)+j] = lcs[(i+1)*(m+1)+j+1] + 1
+			} else {
+				lcs[i*(m+1)+j] = max(lcs[(i+1)*(m+1)+j], lcs[i*(m+1)+j+1])
+			}
+		}
+	}
+
+	a.matches = make([]int, n)
+	for i := range a.matches {
+		a.matches[i] = -1
+	}
+	for i, j := 0, 0; i < n && j < m; {
+		switch {
+		case a.message[i] == a.response[j] && lcs[i*(m+1)+j] == lcs[(i+1)*(m+1)+j+1]+1:
+			a.matches[i] = j
+			i++
+			j++
+		case lcs[(i+1)*(m+1)+j] >= lcs[i*(m+1)+j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+
+	return a
+}
+
+// counterpart returns the part of the response aligned with the given byte span of the message:
+// the text between the response runes matching the closest aligned runes around the span
+func (a *alignment) counterpart(message string, start, end int) string {
+	runeStart := utf8.RuneCountInString(message[:start])
+	runeEnd := runeStart + utf8.RuneCountInString(message[start:end])
+
+	before := -1
+	for i := runeStart - 1; i >= 0; i-- {
+		if a.matches[i] != -1 {
+			before = a.matches[i]
+			break
+		}
+	}
+	after := len(a.response)
+	for i := runeEnd; i < len(a.matches); i++ {
+		if a.matches[i] != -1 {
+			after = a.matches[i]
+			break
+		}
+	}
+
+	if before+1 >= after {
+		return ""
+	}
+	return string(a.response[before+1 : after])
+}
+
+// preservedItems tells, for each delimiter and expression item located in the message (by index),
+// whether it is found in the response. The response is only aligned with the message when the
+// counts of a token are ambiguous, i.e. when some but not all of its occurrences are missing.
+// The candidate and the response are expected to be normalized the same way.
+func preservedItems(candidate generator.Candidate, response string) map[int]bool {
+	spans := locateItems(candidate)
+
+	expected := make(map[string]int)
+	for _, span := range spans {
+		expected[span.item.Token]++
+	}
+
+	var alignment *alignment
+	preserved := make(map[int]bool, len(spans))
+	for _, span := range spans {
+		if span.item.Type != generator.Delimiter && span.item.Type != generator.Expression {
+			continue
+		}
+
+		count := strings.Count(response, span.item.Token)
+		switch {
+		case count >= expected[span.item.Token]:
+			preserved[span.index] = true
+		case count == 0:
+			preserved[span.index] = false
+		default:
+			if alignment == nil {
+				alignment = align(candidate.Message, response)
+			}
+			counterpart := alignment.counterpart(candidate.Message, span.start, span.end)
+			preserved[span.index] = strings.TrimSpace(counterpart) == span.item.Token
+		}
+	}
+
+	return preserved
+}
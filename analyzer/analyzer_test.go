@@ -0,0 +1,78 @@
+package analyzer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/glethuillier/deLLMiter/generator"
+)
+
+func TestAreIdenticalQuotationStats(t *testing.T) {
+	candidate := generator.Candidate{
+		Message: "hello <|eot_id|> the token \"<|eot_id|>\" ends a turn",
+		Items: []generator.Item{
+			{Type: generator.Expression, Token: "hello"},
+			{Type: generator.Delimiter, Token: "<|eot_id|>"},
+			{Type: generator.HigherOrder, Token: "the token \"<|eot_id|>\" ends a turn", Delimiter: "<|eot_id|>", Depth: 1},
+		},
+	}
+
+	a := NewAnalyzer()
+
+	// the first-order delimiter is swallowed while the quoted one survives
+	identical, _ := a.AreIdentical(candidate, "hello the token \"<|eot_id|>\" ends a turn")
+	if identical {
+		t.Fatalf("expected a mismatch")
+	}
+
+	stats := a.QuotationStats["<|eot_id|>"]
+	if stats[0] == nil || stats[0].Trials != 1 || stats[0].Preserved != 0 {
+		t.Errorf("unexpected depth 0 stats: %+v", stats[0])
+	}
+	if stats[1] == nil || stats[1].Trials != 1 || stats[1].Preserved != 1 {
+		t.Errorf("unexpected depth 1 stats: %+v", stats[1])
+	}
+
+	// an identical echo preserves every occurrence
+	if identical, _ = a.AreIdentical(candidate, candidate.Message); !identical {
+		t.Fatalf("expected an identical echo")
+	}
+	if stats[0].Trials != 2 || stats[0].Preserved != 1 {
+		t.Errorf("unexpected depth 0 stats: %+v", stats[0])
+	}
+	if rate := stats[1].PreservationRate(); rate != 1 {
+		t.Errorf("expected depth 1 preservation rate of 1, got %v", rate)
+	}
+}
+
+func TestAreIdenticalQuotationStatsOfEscapedForms(t *testing.T) {
+	candidate := generator.Candidate{
+		Message: "{\n  \"apple\": \"the token \\\"<|eot_id|>\\\" ends a turn\"\n}",
+		Items: []generator.Item{
+			{Type: generator.HigherOrder, Token: "the token \"<|eot_id|>\" ends a turn", Delimiter: "<|eot_id|>", Depth: 1,
+				Rendered: "the token \\\"<|eot_id|>\\\" ends a turn"},
+		},
+		Format: generator.FormatJSON,
+	}
+
+	a := NewAnalyzer()
+	if identical, _ := a.AreIdentical(candidate, candidate.Message); !identical {
+		t.Fatalf("expected an identical echo")
+	}
+	if stat := a.QuotationStats["<|eot_id|>"][1]; stat == nil || stat.Trials != 1 || stat.Preserved != 1 {
+		t.Errorf("expected the escaped mention to be preserved, got %+v", stat)
+	}
+}
+
+func TestReportQuotations(t *testing.T) {
+	a := NewAnalyzer()
+	a.QuotationStats["<s>"] = map[int]*QuotationStat{2: {Trials: 4, Preserved: 1}, 0: {Trials: 2, Preserved: 2}}
+
+	var builder strings.Builder
+	if err := a.ReportQuotations(&builder); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "<s>\n  depth 0: 2/2 preserved (100%)\n  depth 2: 1/4 preserved (25%)\n"; builder.String() != expected {
+		t.Errorf("expected %q, got %q", expected, builder.String())
+	}
+}
@@ -0,0 +1,141 @@
+package analyzer
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/glethuillier/deLLMiter/generator"
+)
+
+// Compliance tells how well a response follows the echo instruction
+type Compliance string
+
+const (
+	// Compliant responses are a bare echo of the candidate, possibly altered
+	Compliant Compliance = "compliant"
+	// Wrapped responses embed the echo in quotes, code fences, a preamble or trailing commentary
+	Wrapped Compliance = "wrapped"
+	// Refusal responses decline to repeat the candidate
+	Refusal Compliance = "refusal"
+	// OffTask responses do not echo the candidate at all (e.g. the model answered it)
+	OffTask Compliance = "off-task"
+	// Empty responses contain nothing but whitespace
+	Empty Compliance = "empty"
+)
+
+// IsCompliant tells whether the response contains an echo worth analyzing
+func (c Compliance) IsCompliant() bool {
+	return c == Compliant || c == Wrapped
+}
+
+var (
+	// codeFencePattern matches a fenced code block and captures its content
+	codeFencePattern = regexp.MustCompile("(?s)```[\\w-]*[ \\t]*\\n?(.*?)\\n?```")
+	// preamblePattern matches introductions such as "Sure! Here's your message:"
+	preamblePattern = regexp.MustCompile(`(?i)^(sure|certainly|of course|ok|okay|absolutely|here|as requested|the message)\b[^\n:]{0,80}:[ \t]*\n*`)
+	// refusalPattern matches the usual openings of a refusal
+	refusalPattern = regexp.MustCompile(`(?i)^(i'm sorry|i am sorry|sorry|i can't|i cannot|i can not|i won't|i will not|i'm unable|i am unable|i'm not able|i am not able|as an ai)\b`)
+	// quotePairs are the opening and closing quotes stripped around an echo
+	quotePairs = [][2]string{{`"`, `"`}, {"'", "'"}, {"`", "`"}, {"“", "”"}, {"‘", "’"}, {"«", "»"}}
+)
+
+// ClassifyCompliance strips the common wrappers of an echo (code fences, preambles, trailing
+// commentary and quotes), unless they are part of the candidate itself, and classifies the response.
+// It returns the normalized echo, on which the delimiter analysis should run. The categories are
+// tallied in ComplianceCounts.
+func (a *Analyzer) ClassifyCompliance(candidate generator.Candidate, response string) (Compliance, string) {
+	compliance, echo := classifyCompliance(candidate, response)
+	a.ComplianceCounts[compliance]++
+	return compliance, echo
+}
+
+func classifyCompliance(candidate generator.Candidate, response string) (Compliance, string) {
+	message := strings.TrimSpace(candidate.Message)
+	echo := strings.TrimSpace(response)
+	if echo == "" {
+		return Empty, ""
+	}
+
+	if !strings.Contains(message, "```") {
+		if match := codeFencePattern.FindStringSubmatch(echo); match != nil {
+			echo = strings.TrimSpace(match[1])
+		}
+	}
+
+	if match := preamblePattern.FindString(echo); match != "" && !hasPrefixFold(message, strings.TrimSpace(match)) {
+		echo = strings.TrimSpace(echo[len(match):])
+	}
+
+	// trailing commentary is separated from the echo by a blank line, and holds none of the expressions
+	// and delimiters of the candidate (otherwise the blank line was inserted in the echo itself)
+	if !strings.Contains(message, "\n\n") {
+		if index := strings.Index(echo, "\n\n"); index != -1 &&
+			!holdsExpression(candidate, echo[index:]) && !holdsDelimiter(candidate, echo[index:]) {
+			echo = strings.TrimSpace(echo[:index])
+		}
+	}
+
+	for _, pair := range quotePairs {
+		if strings.HasPrefix(message, pair[0]) {
+			continue
+		}
+		if len(echo) >= len(pair[0])+len(pair[1]) && strings.HasPrefix(echo, pair[0]) && strings.HasSuffix(echo, pair[1]) {
+			echo = strings.TrimSpace(echo[len(pair[0]) : len(echo)-len(pair[1])])
+			break
+		}
+	}
+
+	if !echoesExpressions(candidate, echo) {
+		if refusalPattern.MatchString(strings.TrimSpace(response)) && !refusalPattern.MatchString(message) {
+			return Refusal, echo
+		}
+		return OffTask, echo
+	}
+
+	if echo != strings.TrimSpace(response) {
+		return Wrapped, echo
+	}
+	return Compliant, echo
+}
+
+// echoesExpressions tells whether at least one expression of the candidate is found in the echo.
+// Candidates without expressions cannot be told apart from an unrelated response and are accepted.
+func echoesExpressions(candidate generator.Candidate, echo string) bool {
+	if holdsExpression(candidate, echo) {
+		return true
+	}
+	for _, item := range candidate.Items {
+		if item.Type == generator.Expression {
+			return false
+		}
+	}
+	return true
+}
+
+// holdsExpression tells whether at least one expression of the candidate is found in the text, ignoring case
+func holdsExpression(candidate generator.Candidate, text string) bool {
+	for _, item := range candidate.Items {
+		if item.Type == generator.Expression && strings.Contains(strings.ToLower(text), strings.ToLower(item.Form())) {
+			return true
+		}
+	}
+	return false
+}
+
+// holdsDelimiter tells whether at least one delimiter of the candidate, used or mentioned, is found in the text
+func holdsDelimiter(candidate generator.Candidate, text string) bool {
+	for _, item := range candidate.Items {
+		switch {
+		case item.Type == generator.Delimiter && strings.Contains(text, item.Form()):
+			return true
+		case item.Type == generator.HigherOrder && strings.Contains(text, item.Delimiter):
+			return true
+		}
+	}
+	return false
+}
+
+// hasPrefixFold tells whether s starts with prefix, ignoring case
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
@@ -0,0 +1,119 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/glethuillier/deLLMiter/generator"
+)
+
+func TestClassifyCompliance(t *testing.T) {
+	candidate := generator.Candidate{
+		Message: "hello <|eot_id|> world",
+		Items: []generator.Item{
+			{Type: generator.Expression, Token: "hello"},
+			{Type: generator.Delimiter, Token: "<|eot_id|>"},
+			{Type: generator.Expression, Token: "world"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		response   string
+		compliance Compliance
+		echo       string
+	}{
+		{
+			name:       "bare echo",
+			response:   "hello <|eot_id|> world\n",
+			compliance: Compliant,
+			echo:       "hello <|eot_id|> world",
+		},
+		{
+			name:       "altered bare echo",
+			response:   "hello  world",
+			compliance: Compliant,
+			echo:       "hello  world",
+		},
+		{
+			name:       "preamble on the same line",
+			response:   "Sure! Here's your message: hello <|eot_id|> world",
+			compliance: Wrapped,
+			echo:       "hello <|eot_id|> world",
+		},
+		{
+			name:       "preamble, quotes and trailing commentary",
+			response:   "Here is the message:\n\n\"hello  world\"\n\nLet me know if you need anything else!",
+			compliance: Wrapped,
+			echo:       "hello  world",
+		},
+		{
+			name:       "blank line inserted in the echo",
+			response:   "hello\n\nworld",
+			compliance: Compliant,
+			echo:       "hello\n\nworld",
+		},
+		{
+			name:       "blank line inserted before a trailing delimiter",
+			response:   "hello world\n\n<|eot_id|>",
+			compliance: Compliant,
+			echo:       "hello world\n\n<|eot_id|>",
+		},
+		{
+			name:       "code fence",
+			response:   "```text\nhello <|eot_id|> world\n```",
+			compliance: Wrapped,
+			echo:       "hello <|eot_id|> world",
+		},
+		{
+			name:       "typographic quotes",
+			response:   "“hello <|eot_id|> world”",
+			compliance: Wrapped,
+			echo:       "hello <|eot_id|> world",
+		},
+		{
+			name:       "refusal",
+			response:   "I'm sorry, but I can't help with that.",
+			compliance: Refusal,
+			echo:       "I'm sorry, but I can't help with that.",
+		},
+		{
+			name:       "off-task answer",
+			response:   "The capital of France is Paris.",
+			compliance: OffTask,
+			echo:       "The capital of France is Paris.",
+		},
+		{
+			name:       "empty response",
+			response:   " \n",
+			compliance: Empty,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a := NewAnalyzer()
+			compliance, echo := a.ClassifyCompliance(candidate, tc.response)
+			if compliance != tc.compliance {
+				t.Errorf("expected compliance %s, got %s", tc.compliance, compliance)
+			}
+			if echo != tc.echo {
+				t.Errorf("expected echo %q, got %q", tc.echo, echo)
+			}
+			if a.ComplianceCounts[tc.compliance] != 1 {
+				t.Errorf("expected the %s category to be tallied, got %v", tc.compliance, a.ComplianceCounts)
+			}
+		})
+	}
+}
+
+func TestClassifyComplianceKeepsWrappersOfTheCandidate(t *testing.T) {
+	candidate := generator.Candidate{
+		Message: "\"Sure: hello\"",
+		Items:   []generator.Item{{Type: generator.Expression, Token: "\"Sure: hello\""}},
+	}
+
+	compliance, echo := NewAnalyzer().ClassifyCompliance(candidate, "\"Sure: hello\"")
+	if compliance != Compliant || echo != candidate.Message {
+		t.Errorf("expected the candidate to be kept verbatim, got %s %q", compliance, echo)
+	}
+}
@@ -0,0 +1,206 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/glethuillier/deLLMiter/generator"
+)
+
+// FindingKind categorizes the findings of the detectors
+type FindingKind string
+
+const (
+	MissingDelimiter     FindingKind = "missing_delimiter"
+	TerminatingDelimiter FindingKind = "terminating_delimiter"
+	SwallowedDelimiter   FindingKind = "swallowed_delimiter"
+	SubstitutedDelimiter FindingKind = "substituted_delimiter"
+	EmittedDelimiter     FindingKind = "emitted_delimiter"
+	Leak                 FindingKind = "leak"
+	ConfusedTurns        FindingKind = "confused_turns"
+	ObeyedRoleProbe      FindingKind = "obeyed_role_probe"
+	ConsumedSpan         FindingKind = "consumed_span"
+	PositionalSwallow    FindingKind = "positional_swallow"
+	AnomalousPair        FindingKind = "anomalous_pair"
+)
+
+// Finding is a piece of evidence reported by a detector about a response
+type Finding struct {
+	Detector string
+	Kind     FindingKind
+	// Delimiter is the delimiter involved, if any
+	Delimiter string
+	// Evidence describes what was observed in the response
+	Evidence string
+	// Detail holds the typed result of the detector (e.g. a Substitution or a LeakEvent)
+	Detail any
+}
+
+func (f Finding) String() string {
+	if f.Delimiter == "" {
+		return fmt.Sprintf("[%s] %s: %s", f.Detector, f.Kind, f.Evidence)
+	}
+	return fmt.Sprintf("[%s] %s %s: %s", f.Detector, f.Kind, f.Delimiter, f.Evidence)
+}
+
+// Observation is a response to a candidate, as seen by the detectors
+type Observation struct {
+	Candidate generator.Candidate
+	// Raw is the response of the model
+	Raw string
+	// Echo is the response stripped of its wrappers, on which the delimiter analysis runs
+	Echo       string
+	Compliance Compliance
+	// NormalizedMessage and NormalizedEcho are the forms of the candidate message and of the echo
+	// compared to tell whether they are identical
+	NormalizedMessage string
+	NormalizedEcho    string
+	// Identical tells whether the normalized echo matches the normalized candidate message
+	Identical bool
+}
+
+// Detector is a heuristic run on each response. Detectors analyzing the echo skip the non-compliant responses.
+type Detector interface {
+	// Name identifies the detector on the command line and in the findings
+	Name() string
+	// Detect updates the tallies of the detector and returns its findings about the observation
+	Detect(observation Observation) []Finding
+}
+
+// detectorFactories create the detectors of the pipeline, in execution order
+var detectorFactories = []struct {
+	name        string
+	newDetector func(a *Analyzer) Detector
+}{
+	{"turns", func(a *Analyzer) Detector { return &TurnDetector{a} }},
+	{"leakage", func(a *Analyzer) Detector { return &LeakageDetector{a} }},
+	{"emission", func(a *Analyzer) Detector { return &EmissionDetector{a} }},
+	{"role-probe", func(a *Analyzer) Detector { return &RoleProbeDetector{a} }},
+	{"count-mismatch", func(a *Analyzer) Detector { return &CountMismatchDetector{a} }},
+	{"position", func(a *Analyzer) Detector { return &PositionDetector{a} }},
+	{"pairs", func(a *Analyzer) Detector { return &PairDetector{a} }},
+	{"span-loss", func(a *Analyzer) Detector { return &SpanLossDetector{a} }},
+	{"truncation", func(a *Analyzer) Detector { return &TruncationDetector{a} }},
+	{"substitution", func(a *Analyzer) Detector { return &SubstitutionDetector{a} }},
+}
+
+// DetectorNames lists the available detectors, in execution order
+func DetectorNames() []string {
+	names := make([]string, 0, len(detectorFactories))
+	for _, factory := range detectorFactories {
+		names = append(names, factory.name)
+	}
+	return names
+}
+
+// ParseDetectors selects the detectors from comma-separated lists of enabled (all when empty)
+// and skipped detector names
+func ParseDetectors(enabled, skipped string) ([]string, error) {
+	known := make(map[string]bool)
+	for _, name := range DetectorNames() {
+		known[name] = true
+	}
+
+	parse := func(list string) (map[string]bool, error) {
+		names := make(map[string]bool)
+		for _, name := range strings.Split(list, ",") {
+			if name = strings.TrimSpace(strings.ToLower(name)); name == "" {
+				continue
+			}
+			if !known[name] {
+				return nil, fmt.Errorf("unknown detector: %q (available: %s)", name, strings.Join(DetectorNames(), ", "))
+			}
+			names[name] = true
+		}
+		return names, nil
+	}
+
+	enabledNames, err := parse(enabled)
+	if err != nil {
+		return nil, err
+	}
+	skippedNames, err := parse(skipped)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, name := range DetectorNames() {
+		if (len(enabledNames) == 0 || enabledNames[name]) && !skippedNames[name] {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// Pipeline runs a selection of detectors on each response
+type Pipeline struct {
+	analyzer  *Analyzer
+	detectors []Detector
+}
+
+// Result gathers the findings of the detectors about an observation
+type Result struct {
+	Observation
+	Findings []Finding
+}
+
+// NewPipeline creates a pipeline running the named detectors on the tallies of the analyzer
+func (a *Analyzer) NewPipeline(names []string) (*Pipeline, error) {
+	p := &Pipeline{analyzer: a}
+	for _, name := range names {
+		found := false
+		for _, factory := range detectorFactories {
+			if factory.name == name {
+				p.detectors = append(p.detectors, factory.newDetector(a))
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown detector: %q", name)
+		}
+	}
+	return p, nil
+}
+
+// Use appends a detector to the pipeline
+func (p *Pipeline) Use(detector Detector) {
+	p.detectors = append(p.detectors, detector)
+}
+
+// Names returns the names of the detectors of the pipeline, in execution order
+func (p *Pipeline) Names() []string {
+	names := make([]string, 0, len(p.detectors))
+	for _, detector := range p.detectors {
+		names = append(names, detector.Name())
+	}
+	return names
+}
+
+// Run classifies the response, strips its wrappers, normalizes the echo and runs the detectors. The delimiter
+// tallies behind the confidences and the verdicts (see AreIdentical) are updated whichever detectors run.
+func (p *Pipeline) Run(candidate generator.Candidate, response string) Result {
+	compliance, echo := p.analyzer.ClassifyCompliance(candidate, response)
+	result := Result{Observation: Observation{
+		Candidate:         candidate,
+		Raw:               response,
+		Echo:              echo,
+		Compliance:        compliance,
+		NormalizedMessage: p.analyzer.normalizer.Normalize(candidate.Message),
+		NormalizedEcho:    p.analyzer.normalizer.Normalize(echo),
+	}}
+	if compliance.IsCompliant() {
+		result.Identical, _ = p.analyzer.AreIdentical(candidate, echo)
+	}
+
+	for _, detector := range p.detectors {
+		findings := detector.Detect(result.Observation)
+		for i := range findings {
+			findings[i].Detector = detector.Name()
+		}
+		result.Findings = append(result.Findings, findings...)
+	}
+
+	return result
+}
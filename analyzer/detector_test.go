@@ -0,0 +1,240 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/glethuillier/deLLMiter/generator"
+)
+
+var detectorCandidate = generator.Candidate{
+	Message: "hello <|eot_id|> world",
+	Items: []generator.Item{
+		{Type: generator.Expression, Token: "hello"},
+		{Type: generator.Delimiter, Token: "<|eot_id|>"},
+		{Type: generator.Expression, Token: "world"},
+	},
+}
+
+func findingKinds(findings []Finding) []FindingKind {
+	var kinds []FindingKind
+	for _, finding := range findings {
+		kinds = append(kinds, finding.Kind)
+	}
+	return kinds
+}
+
+func TestParseDetectors(t *testing.T) {
+	tests := []struct {
+		name        string
+		enabled     string
+		skipped     string
+		expected    []string
+		expectError bool
+	}{
+		{name: "all by default", expected: DetectorNames()},
+		{name: "selection in pipeline order", enabled: "substitution, Turns", expected: []string{"turns", "substitution"}},
+		{name: "skipped detectors", enabled: "turns,leakage", skipped: "leakage", expected: []string{"turns"}},
+		{name: "unknown detector", enabled: "magic", expectError: true},
+		{name: "unknown skipped detector", skipped: "magic", expectError: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			names, err := ParseDetectors(tc.enabled, tc.skipped)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("expected an error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(names, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, names)
+			}
+		})
+	}
+}
+
+func TestDetectors(t *testing.T) {
+	tests := []struct {
+		name        string
+		newDetector func(a *Analyzer) Detector
+		observation Observation
+		expected    []FindingKind
+	}{
+		{
+			name:        "count mismatch",
+			newDetector: func(a *Analyzer) Detector { return &CountMismatchDetector{a} },
+			observation: Observation{Candidate: detectorCandidate, Echo: "hello  world", NormalizedEcho: "hello  world", Compliance: Compliant},
+			expected:    []FindingKind{MissingDelimiter},
+		},
+		{
+			name:        "count mismatch of an identical echo",
+			newDetector: func(a *Analyzer) Detector { return &CountMismatchDetector{a} },
+			observation: Observation{Candidate: detectorCandidate, Echo: detectorCandidate.Message, Compliance: Compliant, Identical: true},
+		},
+		{
+			name:        "substitution",
+			newDetector: func(a *Analyzer) Detector { return &SubstitutionDetector{a} },
+			observation: Observation{Candidate: detectorCandidate, Echo: "hello [EOT] world", Compliance: Compliant},
+			expected:    []FindingKind{SubstitutedDelimiter},
+		},
+		{
+			name:        "truncation",
+			newDetector: func(a *Analyzer) Detector { return &TruncationDetector{a} },
+			observation: Observation{Candidate: detectorCandidate, Echo: "hello", Compliance: Compliant},
+			expected:    []FindingKind{TerminatingDelimiter},
+		},
+		{
+			name:        "emission",
+			newDetector: func(a *Analyzer) Detector { return &EmissionDetector{a} },
+			observation: Observation{Candidate: detectorCandidate, Raw: "hello <|eot_id|> world<|im_end|>", Compliance: Compliant},
+			expected:    []FindingKind{EmittedDelimiter},
+		},
+		{
+			name:        "emission in a refusal",
+			newDetector: func(a *Analyzer) Detector { return &EmissionDetector{a} },
+			observation: Observation{Candidate: detectorCandidate, Raw: "I cannot do that.<|im_end|>", Compliance: Refusal},
+			expected:    []FindingKind{EmittedDelimiter},
+		},
+		{
+			name:        "span loss",
+			newDetector: func(a *Analyzer) Detector { return &SpanLossDetector{a} },
+			observation: Observation{
+				Candidate: generator.Candidate{
+					Message: "hello <think> plan </think> world",
+					Items: []generator.Item{
+						{Type: generator.Expression, Token: "hello"},
+						{Type: generator.Delimiter, Token: "<think>"},
+						{Type: generator.Expression, Token: "plan"},
+						{Type: generator.Delimiter, Token: "</think>"},
+						{Type: generator.Expression, Token: "world"},
+					},
+				},
+				Echo:       "hello world",
+				Compliance: Compliant,
+			},
+			expected: []FindingKind{ConsumedSpan},
+		},
+		{
+			name:        "echo detectors skip non-compliant responses",
+			newDetector: func(a *Analyzer) Detector { return &SubstitutionDetector{a} },
+			observation: Observation{Candidate: detectorCandidate, Echo: "hello [EOT] world", Compliance: OffTask},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			findings := tc.newDetector(NewAnalyzer()).Detect(tc.observation)
+			if kinds := findingKinds(findings); !reflect.DeepEqual(kinds, tc.expected) {
+				t.Errorf("expected findings %v, got %+v", tc.expected, findings)
+			}
+		})
+	}
+}
+
+// canaryDetector reports the responses holding a fixed word
+type canaryDetector struct{}
+
+func (canaryDetector) Name() string { return "canary" }
+
+func (canaryDetector) Detect(o Observation) []Finding {
+	if o.Raw == "" {
+		return nil
+	}
+	return []Finding{{Kind: "canary", Evidence: o.Raw}}
+}
+
+func TestPipelineRun(t *testing.T) {
+	a := NewAnalyzer()
+	pipeline, err := a.NewPipeline([]string{"count-mismatch", "substitution"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pipeline.Use(canaryDetector{})
+
+	if names := pipeline.Names(); !reflect.DeepEqual(names, []string{"count-mismatch", "substitution", "canary"}) {
+		t.Errorf("unexpected detectors %v", names)
+	}
+
+	result := pipeline.Run(detectorCandidate, "Sure! Here it is: \"hello [EOT] world\"")
+	if result.Compliance != Wrapped || result.Echo != "hello [EOT] world" || result.Identical {
+		t.Errorf("unexpected observation %+v", result.Observation)
+	}
+
+	expected := []Finding{
+		{Detector: "count-mismatch", Kind: MissingDelimiter, Delimiter: "<|eot_id|>", Evidence: "0 occurrences found, 1 expected"},
+		{Detector: "substitution", Kind: SubstitutedDelimiter, Delimiter: "<|eot_id|>", Evidence: `replaced by "[EOT]"`,
+			Detail: Substitution{Delimiter: "<|eot_id|>", Replacement: "[EOT]"}},
+		{Detector: "canary", Kind: "canary", Evidence: result.Raw},
+	}
+	if !reflect.DeepEqual(result.Findings, expected) {
+		t.Errorf("expected %+v, got %+v", expected, result.Findings)
+	}
+
+	result = pipeline.Run(detectorCandidate, detectorCandidate.Message)
+	if !result.Identical || len(result.Findings) != 1 {
+		t.Errorf("expected an identical echo with the canary finding only, got %+v", result)
+	}
+}
+
+func TestPipelineRunTalliesWithoutDetectors(t *testing.T) {
+	a := NewAnalyzer()
+	pipeline, err := a.NewPipeline(nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for i := 0; i < threshold; i++ {
+		if result := pipeline.Run(detectorCandidate, "hello world"); result.Identical || len(result.Findings) != 0 {
+			t.Fatalf("unexpected result %+v", result)
+		}
+	}
+	pipeline.Run(detectorCandidate, "I cannot do that.")
+
+	if stat := a.DelimiterStats["<|eot_id|>"]; stat == nil || stat.Trials != threshold || stat.Misses != threshold {
+		t.Errorf("expected the non-compliant response to be left out of the delimiter stats, got %+v", stat)
+	}
+	if verdicts := a.Verdicts(); !reflect.DeepEqual(verdicts, []string{"<|eot_id|>"}) {
+		t.Errorf("expected the delimiter to be reported, got %v", verdicts)
+	}
+}
+
+func TestPipelineRunCountsNormalizedDelimiters(t *testing.T) {
+	candidate := generator.Candidate{
+		Message: "hello <\uff5cend\u2581of\u2581sentence\uff5c> world",
+		Items: []generator.Item{
+			{Type: generator.Expression, Token: "hello"},
+			{Type: generator.Delimiter, Token: "<\uff5cend\u2581of\u2581sentence\uff5c>"},
+			{Type: generator.Expression, Token: "world"},
+		},
+	}
+
+	a := NewAnalyzer()
+	a.UseNormalizer(Normalizer{NormalizeNFKC, NormalizeCase})
+	pipeline, err := a.NewPipeline([]string{"count-mismatch", "truncation", "substitution"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the fullwidth bars are folded in the response, which is nevertheless missing a word
+	result := pipeline.Run(candidate, "hello <|end\u2581of\u2581sentence|>")
+	if result.Identical {
+		t.Fatalf("expected a mismatch")
+	}
+	if len(result.Findings) != 0 {
+		t.Errorf("expected the folded delimiter to be counted as preserved, got %+v", result.Findings)
+	}
+	if stat := a.DelimiterStats[candidate.Items[1].Token]; stat == nil || stat.Trials != 1 || stat.Misses != 0 {
+		t.Errorf("unexpected delimiter stats %+v", stat)
+	}
+}
+
+func TestNewPipelineRejectsUnknownDetectors(t *testing.T) {
+	if _, err := NewAnalyzer().NewPipeline([]string{"magic"}); err == nil {
+		t.Errorf("expected an error for an unknown detector")
+	}
+}
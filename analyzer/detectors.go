@@ -0,0 +1,247 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TurnDetector looks for evidence that the model misread the turn structure of the conversation
+type TurnDetector struct{ analyzer *Analyzer }
+
+func (d *TurnDetector) Name() string { return "turns" }
+
+func (d *TurnDetector) Detect(o Observation) []Finding {
+	var findings []Finding
+	for _, confusion := range d.analyzer.CheckTurnStructure(o.Candidate, o.Raw) {
+		findings = append(findings, Finding{
+			Kind:     ConfusedTurns,
+			Evidence: fmt.Sprintf("%s (turn %d): %s", confusion.Kind, confusion.Turn, confusion.Evidence),
+			Detail:   confusion,
+		})
+	}
+	return findings
+}
+
+// LeakageDetector looks for the reference texts (e.g. the system prompt) in the raw response
+type LeakageDetector struct{ analyzer *Analyzer }
+
+func (d *LeakageDetector) Name() string { return "leakage" }
+
+func (d *LeakageDetector) Detect(o Observation) []Finding {
+	var findings []Finding
+	for _, leak := range d.analyzer.DetectLeaks(o.Candidate, o.Raw) {
+		findings = append(findings, Finding{
+			Kind: Leak,
+			Evidence: fmt.Sprintf("%s (%.0f%%) after delimiters %v: %s",
+				leak.Reference, 100*leak.Fraction, leak.Delimiters, leak.Excerpt),
+			Detail: leak,
+		})
+	}
+	return findings
+}
+
+// EmissionDetector looks for delimiters output by the model although absent from the input
+type EmissionDetector struct{ analyzer *Analyzer }
+
+func (d *EmissionDetector) Name() string { return "emission" }
+
+func (d *EmissionDetector) Detect(o Observation) []Finding {
+	var findings []Finding
+	for _, emission := range d.analyzer.DetectEmissions(o.Candidate, o.Raw) {
+		evidence := fmt.Sprintf("emitted %d times", emission.Count)
+		if emission.Known {
+			evidence += " (known delimiter)"
+		}
+		findings = append(findings, Finding{
+			Kind:      EmittedDelimiter,
+			Delimiter: emission.Token,
+			Evidence:  evidence,
+			Detail:    emission,
+		})
+	}
+	return findings
+}
+
+// RoleProbeDetector checks whether the model obeyed the forged role header of a role probe
+type RoleProbeDetector struct{ analyzer *Analyzer }
+
+func (d *RoleProbeDetector) Name() string { return "role-probe" }
+
+func (d *RoleProbeDetector) Detect(o Observation) []Finding {
+	if o.Candidate.Canary == "" || !d.analyzer.CheckRoleProbe(o.Candidate, o.Raw) {
+		return nil
+	}
+	return []Finding{{
+		Kind:     ObeyedRoleProbe,
+		Evidence: fmt.Sprintf("forged %s role header obeyed (canary %s)", o.Candidate.Probe, o.Candidate.Canary),
+	}}
+}
+
+// CountMismatchDetector compares the number of occurrences of each delimiter in the candidate and in the echo
+type CountMismatchDetector struct{ analyzer *Analyzer }
+
+func (d *CountMismatchDetector) Name() string { return "count-mismatch" }
+
+func (d *CountMismatchDetector) Detect(o Observation) []Finding {
+	if !o.Compliance.IsCompliant() || o.Identical {
+		return nil
+	}
+
+	expected := expectedDelimiterCounts(o.Candidate)
+	delimiters := make([]string, 0, len(expected))
+	for delimiter := range expected {
+		delimiters = append(delimiters, delimiter)
+	}
+	sort.Strings(delimiters)
+
+	var findings []Finding
+	for _, delimiter := range delimiters {
+		if found := d.analyzer.occurrences(o.NormalizedEcho, delimiter); found != expected[delimiter] {
+			findings = append(findings, Finding{
+				Kind:      MissingDelimiter,
+				Delimiter: delimiter,
+				Evidence:  fmt.Sprintf("%d occurrences found, %d expected", found, expected[delimiter]),
+			})
+		}
+	}
+	return findings
+}
+
+// PositionDetector tallies the swallow rates of the delimiters per position among the items,
+// and reports the positions of the swallowed delimiters
+type PositionDetector struct{ analyzer *Analyzer }
+
+func (d *PositionDetector) Name() string { return "position" }
+
+func (d *PositionDetector) Detect(o Observation) []Finding {
+	if !o.Compliance.IsCompliant() {
+		return nil
+	}
+
+	var findings []Finding
+	for _, swallow := range d.analyzer.RecordPositions(o.Candidate, o.Echo) {
+		findings = append(findings, Finding{
+			Kind:      PositionalSwallow,
+			Delimiter: swallow.Delimiter,
+			Evidence:  fmt.Sprintf("swallowed at position %v", swallow.Positions),
+			Detail:    swallow,
+		})
+	}
+	return findings
+}
+
+// PairDetector tallies the swallow rates of the pairs of delimiters and of the delimiters alone,
+// and reports the anomalous pairs of which a delimiter was swallowed from the echo. The content lost
+// between the delimiters of a pair is the concern of SpanLossDetector.
+type PairDetector struct{ analyzer *Analyzer }
+
+func (d *PairDetector) Name() string { return "pairs" }
+
+func (d *PairDetector) Detect(o Observation) []Finding {
+	if !o.Compliance.IsCompliant() {
+		return nil
+	}
+	swallowed := make(map[[2]string]bool)
+	for _, pair := range d.analyzer.RecordPairs(o.Candidate, o.Echo) {
+		swallowed[pair] = true
+	}
+	if len(swallowed) == 0 {
+		return nil
+	}
+
+	var findings []Finding
+	for _, anomaly := range d.analyzer.PairAnomalies() {
+		if !swallowed[[2]string{anomaly.First, anomaly.Second}] {
+			continue
+		}
+		findings = append(findings, Finding{
+			Kind:      AnomalousPair,
+			Delimiter: anomaly.First,
+			Evidence: fmt.Sprintf("with %s, swallowed %.0f%%/%.0f%% together vs %.0f%%/%.0f%% alone (%d trials)",
+				anomaly.Second, 100*anomaly.FirstRate, 100*anomaly.SecondRate,
+				100*anomaly.FirstSoloRate, 100*anomaly.SecondSoloRate, anomaly.Trials),
+			Detail: anomaly,
+		})
+	}
+	return findings
+}
+
+// SpanLossDetector looks for the expressions between two delimiters that vanished from the echo
+type SpanLossDetector struct{ analyzer *Analyzer }
+
+func (d *SpanLossDetector) Name() string { return "span-loss" }
+
+func (d *SpanLossDetector) Detect(o Observation) []Finding {
+	if !o.Compliance.IsCompliant() {
+		return nil
+	}
+
+	var findings []Finding
+	for _, loss := range d.analyzer.DetectSpanLoss(o.Candidate, o.Echo) {
+		findings = append(findings, Finding{
+			Kind:      ConsumedSpan,
+			Delimiter: loss.Opening,
+			Evidence:  fmt.Sprintf("%v vanished between %s and %s", loss.Expressions, loss.Opening, loss.Closing),
+			Detail:    loss,
+		})
+	}
+	return findings
+}
+
+// TruncationDetector checks whether the echo stops where a delimiter appears
+type TruncationDetector struct{ analyzer *Analyzer }
+
+func (d *TruncationDetector) Name() string { return "truncation" }
+
+func (d *TruncationDetector) Detect(o Observation) []Finding {
+	if !o.Compliance.IsCompliant() || o.Identical {
+		return nil
+	}
+
+	truncation := d.analyzer.AnalyzeTruncation(o.Candidate, o.Echo)
+
+	var findings []Finding
+	if truncation.Delimiter != "" {
+		findings = append(findings, Finding{
+			Kind:      TerminatingDelimiter,
+			Delimiter: truncation.Delimiter,
+			Evidence:  fmt.Sprintf("response cut at byte %d", truncation.Offset),
+			Detail:    truncation,
+		})
+	}
+	for _, delimiter := range truncation.Swallowed {
+		findings = append(findings, Finding{
+			Kind:      SwallowedDelimiter,
+			Delimiter: delimiter,
+			Evidence:  "missing from a response that was not cut short",
+			Detail:    truncation,
+		})
+	}
+	return findings
+}
+
+// SubstitutionDetector aligns the echo with the candidate to find what replaced the missing delimiters
+type SubstitutionDetector struct{ analyzer *Analyzer }
+
+func (d *SubstitutionDetector) Name() string { return "substitution" }
+
+func (d *SubstitutionDetector) Detect(o Observation) []Finding {
+	if !o.Compliance.IsCompliant() || o.Identical {
+		return nil
+	}
+
+	var findings []Finding
+	for _, substitution := range d.analyzer.DetectSubstitutions(o.Candidate, o.Echo) {
+		evidence := "dropped"
+		if substitution.Replacement != "" {
+			evidence = fmt.Sprintf("replaced by %q", substitution.Replacement)
+		}
+		findings = append(findings, Finding{
+			Kind:      SubstitutedDelimiter,
+			Delimiter: substitution.Delimiter,
+			Evidence:  evidence,
+			Detail:    substitution,
+		})
+	}
+	return findings
+}
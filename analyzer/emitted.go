@@ -0,0 +1,118 @@
+package analyzer
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/glethuillier/deLLMiter/generator"
+)
+
+// delimiterPatterns match the strings shaped like the special tokens of the common chat templates
+var delimiterPatterns = []*regexp.Regexp{
+	// <|im_end|>, <|eot_id|>, ...
+	regexp.MustCompile(`<\|[^|<>\s]{1,40}\|>`),
+	// <｜end▁of▁sentence｜>, ...
+	regexp.MustCompile(`<｜[^｜<>]{1,40}｜>`),
+	// [INST], [/INST], [TOOL_CALLS], ...
+	regexp.MustCompile(`\[/?[A-Z][A-Z_]{1,39}\]`),
+	// <s>, </s>, <start_of_turn>, ...
+	regexp.MustCompile(`</?[A-Za-z_][A-Za-z0-9_.\-]{0,39}>`),
+}
+
+// Emission records a delimiter found in a response although it was not part of the input
+type Emission struct {
+	Token string
+	Count int
+	// Known tells whether the token is a known delimiter, rather than a string shaped like a delimiter
+	Known bool
+}
+
+// AddKnownDelimiters registers the delimiters looked for in the responses, in addition to the delimiter patterns
+func (a *Analyzer) AddKnownDelimiters(delimiters []string) {
+	for _, delimiter := range delimiters {
+		if delimiter = strings.TrimSpace(delimiter); delimiter != "" {
+			a.knownDelimiters[delimiter] = struct{}{}
+		}
+	}
+}
+
+// DetectEmissions returns the known delimiters and the delimiter-shaped strings of the response
+// that appear nowhere in the input (the candidate and its conversation history): such tokens,
+// spontaneously emitted by the model, may be leaked special tokens. They are tallied in EmittedCounts.
+func (a *Analyzer) DetectEmissions(candidate generator.Candidate, response string) []Emission {
+	inputs := []string{candidate.Message}
+	for _, turn := range candidate.History {
+		inputs = append(inputs, turn.Message)
+	}
+	input := strings.Join(inputs, "\n")
+
+	counts := make(map[string]int)
+	known := make(map[string]bool)
+
+	for delimiter := range a.knownDelimiters {
+		if strings.Contains(input, delimiter) {
+			continue
+		}
+		if count := strings.Count(response, delimiter); count > 0 {
+			counts[delimiter] = count
+			known[delimiter] = true
+		}
+	}
+
+	for _, pattern := range delimiterPatterns {
+		for _, match := range pattern.FindAllString(response, -1) {
+			if known[match] || strings.Contains(input, match) {
+				continue
+			}
+			counts[match]++
+		}
+	}
+
+	emissions := make([]Emission, 0, len(counts))
+	for token, count := range counts {
+		emissions = append(emissions, Emission{Token: token, Count: count, Known: known[token]})
+		a.EmittedCounts[token] += count
+	}
+	sort.Slice(emissions, func(i, j int) bool {
+		return emissions[i].Token < emissions[j].Token
+	})
+
+	return emissions
+}
+
+// Reports appends the responses holding delimiters absent from the input, and rewrites
+// the total count of each emitted delimiter
+func (d *EmissionDetector) Reports(result Result) []Report {
+	emissions := findingDetails[Emission](result)
+	if len(emissions) == 0 {
+		return nil
+	}
+
+	return []Report{
+		{Name: "emitted.txt", Append: true, Write: func(w io.Writer) error {
+			var builder strings.Builder
+			writeExchange(&builder, result.Candidate, result.Raw)
+			for _, emission := range emissions {
+				kind := "pattern"
+				if emission.Known {
+					kind = "known"
+				}
+				builder.WriteString(fmt.Sprintf("Emitted: %s x%d (%s)\n", emission.Token, emission.Count, kind))
+			}
+			builder.WriteString("\n")
+
+			_, err := io.WriteString(w, builder.String())
+			return err
+		}},
+		{Name: "emitted_counts.txt", Write: func(w io.Writer) error {
+			var builder strings.Builder
+			writeCounts(&builder, d.analyzer.EmittedCounts, "%s: %d\n")
+
+			_, err := io.WriteString(w, builder.String())
+			return err
+		}},
+	}
+}
@@ -0,0 +1,72 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/glethuillier/deLLMiter/generator"
+)
+
+func TestDetectEmissions(t *testing.T) {
+	tests := []struct {
+		name      string
+		candidate generator.Candidate
+		response  string
+		expected  []Emission
+	}{
+		{
+			name:      "known delimiter emitted",
+			candidate: generator.Candidate{Message: "hello world"},
+			response:  "hello world</s></s>",
+			expected:  []Emission{{Token: "</s>", Count: 2, Known: true}},
+		},
+		{
+			name:      "delimiter-shaped strings emitted",
+			candidate: generator.Candidate{Message: "hello world"},
+			response:  "hello world<|im_end|> [/INST] <｜end▁of▁sentence｜>",
+			expected: []Emission{
+				{Token: "<|im_end|>", Count: 1},
+				{Token: "<｜end▁of▁sentence｜>", Count: 1},
+				{Token: "[/INST]", Count: 1},
+			},
+		},
+		{
+			name:      "delimiters of the input are ignored",
+			candidate: generator.Candidate{Message: "hello </s> <|im_end|> world"},
+			response:  "hello </s> <|im_end|> world",
+		},
+		{
+			name: "delimiters of the history are ignored",
+			candidate: generator.Candidate{
+				Message: "hello",
+				History: []generator.Turn{{Role: generator.RoleUser, Message: "[INST] hi"}},
+			},
+			response: "[INST] hello",
+		},
+		{
+			name:      "plain brackets are not delimiters",
+			candidate: generator.Candidate{Message: "a"},
+			response:  "a [1] [note] < b >",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a := NewAnalyzer()
+			a.AddKnownDelimiters([]string{"</s>", "<s>", " "})
+
+			emissions := a.DetectEmissions(tc.candidate, tc.response)
+			if len(emissions) == 0 && len(tc.expected) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(emissions, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, emissions)
+			}
+			for _, emission := range tc.expected {
+				if a.EmittedCounts[emission.Token] != emission.Count {
+					t.Errorf("expected %s to be tallied %d times, got %d", emission.Token, emission.Count, a.EmittedCounts[emission.Token])
+				}
+			}
+		})
+	}
+}
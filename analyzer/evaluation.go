@@ -0,0 +1,151 @@
+package analyzer
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// detectionConfidence is the lower bound of the miss rate above which a delimiter is detected
+const detectionConfidence = 0.5
+
+// Evaluation compares the delimiters detected by the analyzer with the real special tokens of a model
+type Evaluation struct {
+	TruePositives  []string
+	FalsePositives []string
+	FalseNegatives []string
+}
+
+// Precision returns the fraction of detected delimiters that are real special tokens
+func (e Evaluation) Precision() float64 {
+	detected := len(e.TruePositives) + len(e.FalsePositives)
+	if detected == 0 {
+		return 0
+	}
+	return float64(len(e.TruePositives)) / float64(detected)
+}
+
+// Recall returns the fraction of real special tokens that were detected
+func (e Evaluation) Recall() float64 {
+	actual := len(e.TruePositives) + len(e.FalseNegatives)
+	if actual == 0 {
+		return 0
+	}
+	return float64(len(e.TruePositives)) / float64(actual)
+}
+
+// F1 returns the harmonic mean of the precision and the recall
+func (e Evaluation) F1() float64 {
+	precision, recall := e.Precision(), e.Recall()
+	if precision+recall == 0 {
+		return 0
+	}
+	return 2 * precision * recall / (precision + recall)
+}
+
+// Report writes the precision, recall and F1 score of the evaluation,
+// followed by the false positives and the false negatives
+func (e Evaluation) Report(w io.Writer) error {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("Precision: %.2f (%d/%d)\n", e.Precision(),
+		len(e.TruePositives), len(e.TruePositives)+len(e.FalsePositives)))
+	builder.WriteString(fmt.Sprintf("Recall: %.2f (%d/%d)\n", e.Recall(),
+		len(e.TruePositives), len(e.TruePositives)+len(e.FalseNegatives)))
+	builder.WriteString(fmt.Sprintf("F1: %.2f\n", e.F1()))
+
+	sections := []struct {
+		title  string
+		tokens []string
+	}{
+		{"True positives", e.TruePositives},
+		{"False positives", e.FalsePositives},
+		{"False negatives", e.FalseNegatives},
+	}
+	for _, section := range sections {
+		builder.WriteString(fmt.Sprintf("\n%s (%d):\n", section.title, len(section.tokens)))
+		for _, token := range section.tokens {
+			builder.WriteString("  " + token + "\n")
+		}
+	}
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// Evaluate compares the detected delimiters with the ground truth. When tested is not empty,
+// only the delimiters actually sent to the model are considered: special tokens never probed
+// cannot be detected, and are not counted as false negatives.
+func Evaluate(detected, truth, tested []string) Evaluation {
+	detectedSet := toSet(detected)
+	truthSet := toSet(truth)
+
+	if len(tested) > 0 {
+		testedSet := toSet(tested)
+		for token := range truthSet {
+			if _, ok := testedSet[token]; !ok {
+				delete(truthSet, token)
+			}
+		}
+	}
+
+	var evaluation Evaluation
+	for token := range detectedSet {
+		if _, ok := truthSet[token]; ok {
+			evaluation.TruePositives = append(evaluation.TruePositives, token)
+		} else {
+			evaluation.FalsePositives = append(evaluation.FalsePositives, token)
+		}
+	}
+	for token := range truthSet {
+		if _, ok := detectedSet[token]; !ok {
+			evaluation.FalseNegatives = append(evaluation.FalseNegatives, token)
+		}
+	}
+
+	sort.Strings(evaluation.TruePositives)
+	sort.Strings(evaluation.FalsePositives)
+	sort.Strings(evaluation.FalseNegatives)
+
+	return evaluation
+}
+
+// Verdicts returns the delimiters currently considered as used by the model, sorted
+func (a *Analyzer) Verdicts() []string {
+	var verdicts []string
+	for delimiter, count := range a.MissingDelimiterCounts {
+		if count >= threshold {
+			verdicts = append(verdicts, delimiter)
+		}
+	}
+	sort.Strings(verdicts)
+	return verdicts
+}
+
+// Detections returns the delimiters missed more often than not over all the candidates holding them,
+// with 95% confidence, sorted. Unlike Verdicts, they do not reset when a delimiter survives a single echo.
+func (a *Analyzer) Detections() []string {
+	var detections []string
+	for delimiter, stat := range a.DelimiterStats {
+		if stat.Confidence() >= detectionConfidence {
+			detections = append(detections, delimiter)
+		}
+	}
+	sort.Strings(detections)
+	return detections
+}
+
+// Evaluate compares the cumulative detections of the analyzer with the ground truth (see Evaluate)
+func (a *Analyzer) Evaluate(truth, tested []string) Evaluation {
+	return Evaluate(a.Detections(), truth, tested)
+}
+
+func toSet(tokens []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(tokens))
+	for _, token := range tokens {
+		if trimmed := strings.TrimSpace(token); trimmed != "" {
+			set[trimmed] = struct{}{}
+		}
+	}
+	return set
+}
@@ -0,0 +1,125 @@
+package analyzer
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		name              string
+		detected          []string
+		truth             []string
+		tested            []string
+		expected          Evaluation
+		expectedPrecision float64
+		expectedRecall    float64
+		expectedF1        float64
+	}{
+		{
+			name:     "mixed results",
+			detected: []string{"<s>", "</s>", "[INST]", "<b>"},
+			truth:    []string{"<s>", "</s>", "[INST]", "[/INST]", "<unk>"},
+			expected: Evaluation{
+				TruePositives:  []string{"</s>", "<s>", "[INST]"},
+				FalsePositives: []string{"<b>"},
+				FalseNegatives: []string{"<unk>", "[/INST]"},
+			},
+			expectedPrecision: 0.75,
+			expectedRecall:    0.6,
+			expectedF1:        2 * 0.75 * 0.6 / 1.35,
+		},
+		{
+			name:     "untested tokens are ignored",
+			detected: []string{"<s>"},
+			truth:    []string{"<s>", "<unk>"},
+			tested:   []string{"<s>", "<b>"},
+			expected: Evaluation{
+				TruePositives: []string{"<s>"},
+			},
+			expectedPrecision: 1,
+			expectedRecall:    1,
+			expectedF1:        1,
+		},
+		{
+			name:     "duplicates and blanks",
+			detected: []string{" <s> ", "<s>", ""},
+			truth:    []string{"<s>"},
+			expected: Evaluation{
+				TruePositives: []string{"<s>"},
+			},
+			expectedPrecision: 1,
+			expectedRecall:    1,
+			expectedF1:        1,
+		},
+		{
+			name:     "nothing detected",
+			truth:    []string{"<s>"},
+			expected: Evaluation{FalseNegatives: []string{"<s>"}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			evaluation := Evaluate(tc.detected, tc.truth, tc.tested)
+
+			if !reflect.DeepEqual(evaluation, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, evaluation)
+			}
+			if math.Abs(evaluation.Precision()-tc.expectedPrecision) > 1e-9 {
+				t.Errorf("expected precision %v, got %v", tc.expectedPrecision, evaluation.Precision())
+			}
+			if math.Abs(evaluation.Recall()-tc.expectedRecall) > 1e-9 {
+				t.Errorf("expected recall %v, got %v", tc.expectedRecall, evaluation.Recall())
+			}
+			if math.Abs(evaluation.F1()-tc.expectedF1) > 1e-9 {
+				t.Errorf("expected F1 %v, got %v", tc.expectedF1, evaluation.F1())
+			}
+		})
+	}
+}
+
+func TestVerdicts(t *testing.T) {
+	a := NewAnalyzer()
+	a.MissingDelimiterCounts = map[string]int{"<s>": threshold, "</s>": threshold - 1, "[INST]": threshold + 3}
+
+	expected := []string{"<s>", "[INST]"}
+	if verdicts := a.Verdicts(); !reflect.DeepEqual(verdicts, expected) {
+		t.Errorf("expected %q, got %q", expected, verdicts)
+	}
+}
+
+func TestDetections(t *testing.T) {
+	a := NewAnalyzer()
+	a.DelimiterStats = map[string]*DelimiterStat{
+		"<s>":    {Trials: 20, Misses: 20},
+		"</s>":   {Trials: 20, Misses: 2},
+		"[INST]": {Trials: 2, Misses: 2},
+	}
+	// a single echo preserving <s> resets its verdict, but not its detection
+	a.MissingDelimiterCounts = map[string]int{"<s>": 0}
+
+	expected := []string{"<s>"}
+	if detections := a.Detections(); !reflect.DeepEqual(detections, expected) {
+		t.Errorf("expected %q, got %q", expected, detections)
+	}
+	if evaluation := a.Evaluate([]string{"<s>", "</s>"}, nil); !reflect.DeepEqual(evaluation.TruePositives, expected) {
+		t.Errorf("expected the true positives %q, got %q", expected, evaluation.TruePositives)
+	}
+}
+
+func TestEvaluationReport(t *testing.T) {
+	evaluation := Evaluation{TruePositives: []string{"<s>"}, FalsePositives: []string{"[x]"}}
+
+	var builder strings.Builder
+	if err := evaluation.Report(&builder); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "Precision: 0.50 (1/2)\nRecall: 1.00 (1/1)\nF1: 0.67\n\n" +
+		"True positives (1):\n  <s>\n\nFalse positives (1):\n  [x]\n\nFalse negatives (0):\n"
+	if builder.String() != expected {
+		t.Errorf("expected %q, got %q", expected, builder.String())
+	}
+}
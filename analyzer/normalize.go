@@ -0,0 +1,132 @@
+package analyzer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalization is a stage applied to the candidate and to the response before comparing them
+type Normalization string
+
+const (
+	// NormalizeNFC applies the Unicode canonical composition (NFC)
+	NormalizeNFC Normalization = "nfc"
+	// NormalizeNFKC applies the Unicode compatibility composition (NFKC), which also folds fullwidth
+	// forms, compatibility spaces, ligatures, ellipsis and superscript digits
+	NormalizeNFKC Normalization = "nfkc"
+	// NormalizeTrailingNewline trims the trailing line breaks
+	NormalizeTrailingNewline Normalization = "trailing-newline"
+	// NormalizeWhitespace collapses the runs of whitespace into a single space and trims the text
+	NormalizeWhitespace Normalization = "whitespace"
+	// NormalizeQuotes strips a pair of quotes surrounding the text
+	NormalizeQuotes Normalization = "quotes"
+	// NormalizeCase folds the case
+	NormalizeCase Normalization = "case"
+)
+
+// normalizationOrder is the order in which the stages are applied, whatever the order they are listed in
+var normalizationOrder = []Normalization{
+	NormalizeNFC, NormalizeNFKC, NormalizeTrailingNewline, NormalizeWhitespace, NormalizeQuotes, NormalizeCase,
+}
+
+// Normalizer applies normalization stages, in the order of normalizationOrder
+type Normalizer []Normalization
+
+// DefaultNormalizer returns the historical comparison of deLLMiter: case-insensitive
+func DefaultNormalizer() Normalizer {
+	return Normalizer{NormalizeCase}
+}
+
+// ParseNormalizer converts a comma-separated list of stages (e.g. "nfkc,whitespace") into a normalizer.
+// "none" disables the normalization, so that the comparison is exact.
+func ParseNormalizer(list string) (Normalizer, error) {
+	listed := make(map[Normalization]bool)
+	for _, name := range strings.Split(list, ",") {
+		stage := Normalization(strings.TrimSpace(strings.ToLower(name)))
+		if stage == "" || stage == "none" {
+			continue
+		}
+		if !slices.Contains(normalizationOrder, stage) {
+			return nil, fmt.Errorf("unknown normalization: %q", name)
+		}
+		listed[stage] = true
+	}
+
+	normalizer := Normalizer{}
+	for _, stage := range normalizationOrder {
+		if listed[stage] {
+			normalizer = append(normalizer, stage)
+		}
+	}
+	return normalizer, nil
+}
+
+// Normalize applies the stages of the normalizer to a text
+func (n Normalizer) Normalize(text string) string {
+	for _, stage := range normalizationOrder {
+		if !slices.Contains(n, stage) {
+			continue
+		}
+		switch stage {
+		case NormalizeNFC:
+			text = norm.NFC.String(text)
+		case NormalizeNFKC:
+			text = norm.NFKC.String(text)
+		case NormalizeTrailingNewline:
+			text = strings.TrimRight(text, "\r\n")
+		case NormalizeWhitespace:
+			text = strings.Join(strings.Fields(text), " ")
+		case NormalizeQuotes:
+			text = stripQuotes(text)
+		case NormalizeCase:
+			text = strings.ToLower(text)
+		}
+	}
+	return text
+}
+
+// normalizeToken applies to a token the stages of the normalizer acting within a text, so that the token
+// is found in a normalized text. The stages acting on the ends of a text (trailing newline, quotes) do not
+// apply. A token made of whitespace only is erased by the whitespace stage, and is then always deemed found.
+func (n Normalizer) normalizeToken(token string) string {
+	for _, stage := range normalizationOrder {
+		if !slices.Contains(n, stage) {
+			continue
+		}
+		switch stage {
+		case NormalizeNFC:
+			token = norm.NFC.String(token)
+		case NormalizeNFKC:
+			token = norm.NFKC.String(token)
+		case NormalizeWhitespace:
+			token = strings.Join(strings.Fields(token), " ")
+		case NormalizeCase:
+			token = strings.ToLower(token)
+		}
+	}
+	return token
+}
+
+func (n Normalizer) String() string {
+	if len(n) == 0 {
+		return "none"
+	}
+	names := make([]string, len(n))
+	for i, stage := range n {
+		names[i] = string(stage)
+	}
+	return strings.Join(names, ",")
+}
+
+// stripQuotes removes a pair of quotes surrounding the text
+func stripQuotes(text string) string {
+	for _, pair := range quotePairs {
+		if len(text) >= len(pair[0])+len(pair[1]) && strings.HasPrefix(text, pair[0]) && strings.HasSuffix(text, pair[1]) {
+			return text[len(pair[0]) : len(text)-len(pair[1])]
+		}
+	}
+	return text
+}
@@ -0,0 +1,102 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name       string
+		normalizer Normalizer
+		input      string
+		expected   string
+	}{
+		{name: "none", normalizer: Normalizer{}, input: "Hello  World\n", expected: "Hello  World\n"},
+		{name: "nfc", normalizer: Normalizer{NormalizeNFC}, input: "cafe\u0301 nai\u0308ve vie\u0323\u0302t", expected: "caf\u00e9 na\u00efve vi\u1ec7t"},
+		{name: "nfc keeps unknown compositions", normalizer: Normalizer{NormalizeNFC}, input: "q\u0301", expected: "q\u0301"},
+		{name: "nfkc", normalizer: Normalizer{NormalizeNFKC}, input: "＜｜end｜＞ ﬁne…", expected: "<|end|> fine..."},
+		{name: "trailing newline", normalizer: Normalizer{NormalizeTrailingNewline}, input: "hello \n\r\n", expected: "hello "},
+		{name: "whitespace", normalizer: Normalizer{NormalizeWhitespace}, input: " hello \t\n world ", expected: "hello world"},
+		{name: "quotes", normalizer: Normalizer{NormalizeQuotes}, input: "“hello”", expected: "hello"},
+		{name: "case", normalizer: Normalizer{NormalizeCase}, input: "Hello <S>", expected: "hello <s>"},
+		{
+			name:       "stages applied in canonical order",
+			normalizer: Normalizer{NormalizeQuotes, NormalizeWhitespace},
+			input:      "  \"hello\"  ",
+			expected:   "hello",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if normalized := tc.normalizer.Normalize(tc.input); normalized != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, normalized)
+			}
+		})
+	}
+}
+
+func TestParseNormalizer(t *testing.T) {
+	tests := []struct {
+		name        string
+		list        string
+		expected    Normalizer
+		expectError bool
+	}{
+		{name: "ordered stages", list: "case, NFKC,whitespace", expected: Normalizer{NormalizeNFKC, NormalizeWhitespace, NormalizeCase}},
+		{name: "none", list: "none", expected: Normalizer{}},
+		{name: "unknown stage", list: "case,stemming", expectError: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			normalizer, err := ParseNormalizer(tc.list)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("expected an error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(normalizer, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, normalizer)
+			}
+		})
+	}
+}
+
+func TestPipelineNormalization(t *testing.T) {
+	tests := []struct {
+		name       string
+		normalizer Normalizer
+		response   string
+		identical  bool
+	}{
+		{name: "default folds the case", normalizer: DefaultNormalizer(), response: "HELLO <|EOT_ID|> WORLD", identical: true},
+		{name: "default keeps the whitespace", normalizer: DefaultNormalizer(), response: "hello  <|eot_id|> world"},
+		{name: "whitespace collapsed", normalizer: Normalizer{NormalizeWhitespace}, response: "hello  <|eot_id|> world", identical: true},
+		{name: "exact comparison", normalizer: Normalizer{}, response: "Hello <|eot_id|> world"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a := NewAnalyzer()
+			a.UseNormalizer(tc.normalizer)
+			pipeline, err := a.NewPipeline([]string{"count-mismatch"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			result := pipeline.Run(detectorCandidate, tc.response)
+			if result.Identical != tc.identical {
+				t.Errorf("expected identical to be %v, got %+v", tc.identical, result.Observation)
+			}
+			if result.Raw != tc.response || result.NormalizedEcho != tc.normalizer.Normalize(result.Echo) {
+				t.Errorf("expected the raw and normalized forms to be kept, got %+v", result.Observation)
+			}
+		})
+	}
+}
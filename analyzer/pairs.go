@@ -0,0 +1,167 @@
+package analyzer
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/glethuillier/deLLMiter/generator"
+)
+
+const (
+	// minPairTrials is the number of trials, both of a pair and of its delimiters alone,
+	// required before comparing the pair with the solo occurrences
+	minPairTrials = 5
+	// pairAnomalyDelta is the difference of swallow rates above which a pair is anomalous
+	pairAnomalyDelta = 0.3
+)
+
+// PairStat counts the candidates in which a delimiter precedes another one,
+// and how many times each of them was swallowed
+type PairStat struct {
+	Trials          int
+	FirstSwallowed  int
+	SecondSwallowed int
+}
+
+// PairAnomaly reports a pair of delimiters whose swallow rates together differ from their swallow rates alone
+type PairAnomaly struct {
+	First  string
+	Second string
+	Trials int
+	// the swallow rates of each delimiter in the pair and alone
+	FirstRate      float64
+	FirstSoloRate  float64
+	SecondRate     float64
+	SecondSoloRate float64
+}
+
+// Deviation returns the largest difference between the swallow rates in the pair and alone
+func (p PairAnomaly) Deviation() float64 {
+	return math.Max(math.Abs(p.FirstRate-p.FirstSoloRate), math.Abs(p.SecondRate-p.SecondSoloRate))
+}
+
+// RecordPairs tallies, for each ordered pair of distinct delimiters of the candidate, whether each of them
+// was swallowed in PairStats. Candidates holding a single distinct delimiter are tallied in SoloStats.
+// It returns the ordered pairs of which at least one delimiter was swallowed from the response.
+func (a *Analyzer) RecordPairs(candidate generator.Candidate, response string) [][2]string {
+	preserved := preservedItems(a.normalize(candidate, response))
+
+	var indexes []int
+	distinct := make(map[string]struct{})
+	for i, item := range candidate.Items {
+		if _, located := preserved[i]; located && item.Type == generator.Delimiter {
+			indexes = append(indexes, i)
+			distinct[item.Token] = struct{}{}
+		}
+	}
+
+	if len(distinct) == 1 {
+		for _, i := range indexes {
+			token := candidate.Items[i].Token
+			stat, ok := a.SoloStats[token]
+			if !ok {
+				stat = &DelimiterStat{}
+				a.SoloStats[token] = stat
+			}
+			stat.Trials++
+			if !preserved[i] {
+				stat.Misses++
+			}
+		}
+		return nil
+	}
+
+	var swallowed [][2]string
+	for x, i := range indexes {
+		for _, j := range indexes[x+1:] {
+			first, second := candidate.Items[i].Token, candidate.Items[j].Token
+			if first == second {
+				continue
+			}
+
+			if a.PairStats[first] == nil {
+				a.PairStats[first] = make(map[string]*PairStat)
+			}
+			stat, ok := a.PairStats[first][second]
+			if !ok {
+				stat = &PairStat{}
+				a.PairStats[first][second] = stat
+			}
+			stat.Trials++
+			if !preserved[i] {
+				stat.FirstSwallowed++
+			}
+			if !preserved[j] {
+				stat.SecondSwallowed++
+			}
+			if !preserved[i] || !preserved[j] {
+				swallowed = append(swallowed, [2]string{first, second})
+			}
+		}
+	}
+	return swallowed
+}
+
+// PairAnomalies returns the pairs of delimiters whose swallow rates together differ from the swallow rates
+// of the same delimiters alone, the most anomalous first
+func (a *Analyzer) PairAnomalies() []PairAnomaly {
+	var anomalies []PairAnomaly
+	for first, seconds := range a.PairStats {
+		for second, stat := range seconds {
+			firstSolo, secondSolo := a.SoloStats[first], a.SoloStats[second]
+			if stat.Trials < minPairTrials || firstSolo == nil || secondSolo == nil ||
+				firstSolo.Trials < minPairTrials || secondSolo.Trials < minPairTrials {
+				continue
+			}
+
+			anomaly := PairAnomaly{
+				First:          first,
+				Second:         second,
+				Trials:         stat.Trials,
+				FirstRate:      float64(stat.FirstSwallowed) / float64(stat.Trials),
+				FirstSoloRate:  firstSolo.MissRate(),
+				SecondRate:     float64(stat.SecondSwallowed) / float64(stat.Trials),
+				SecondSoloRate: secondSolo.MissRate(),
+			}
+			if anomaly.Deviation() >= pairAnomalyDelta {
+				anomalies = append(anomalies, anomaly)
+			}
+		}
+	}
+
+	sort.Slice(anomalies, func(i, j int) bool {
+		if di, dj := anomalies[i].Deviation(), anomalies[j].Deviation(); di != dj {
+			return di > dj
+		}
+		if anomalies[i].First != anomalies[j].First {
+			return anomalies[i].First < anomalies[j].First
+		}
+		return anomalies[i].Second < anomalies[j].Second
+	})
+
+	return anomalies
+}
+
+// Reports writes the pairs of delimiters whose swallow rates together differ from their swallow rates alone
+func (d *PairDetector) Reports(result Result) []Report {
+	if anomalies := findingDetails[PairAnomaly](result); len(anomalies) == 0 {
+		return nil
+	}
+
+	return []Report{{Name: "pairs.txt", Write: func(w io.Writer) error {
+		var builder strings.Builder
+		for _, anomaly := range d.analyzer.PairAnomalies() {
+			builder.WriteString(fmt.Sprintf("%s ... %s (%d trials)\n", anomaly.First, anomaly.Second, anomaly.Trials))
+			builder.WriteString(fmt.Sprintf("  %s swallowed %.0f%% together, %.0f%% alone\n",
+				anomaly.First, 100*anomaly.FirstRate, 100*anomaly.FirstSoloRate))
+			builder.WriteString(fmt.Sprintf("  %s swallowed %.0f%% together, %.0f%% alone\n",
+				anomaly.Second, 100*anomaly.SecondRate, 100*anomaly.SecondSoloRate))
+		}
+
+		_, err := io.WriteString(w, builder.String())
+		return err
+	}}}
+}
@@ -0,0 +1,84 @@
+package analyzer
+
+import (
+	"testing"
+
+	"github.com/glethuillier/deLLMiter/generator"
+)
+
+func TestRecordPairs(t *testing.T) {
+	pair := generator.Candidate{
+		Message: "[INST] hello [/INST] world",
+		Items: []generator.Item{
+			{Type: generator.Delimiter, Token: "[INST]"},
+			{Type: generator.Expression, Token: "hello"},
+			{Type: generator.Delimiter, Token: "[/INST]"},
+			{Type: generator.Expression, Token: "world"},
+		},
+	}
+	soloOpening := generator.Candidate{
+		Message: "[INST] hello",
+		Items: []generator.Item{
+			{Type: generator.Delimiter, Token: "[INST]"},
+			{Type: generator.Expression, Token: "hello"},
+		},
+	}
+	soloClosing := generator.Candidate{
+		Message: "hello [/INST]",
+		Items: []generator.Item{
+			{Type: generator.Expression, Token: "hello"},
+			{Type: generator.Delimiter, Token: "[/INST]"},
+		},
+	}
+
+	a := NewAnalyzer()
+	detector := &PairDetector{a}
+	for i := 0; i < minPairTrials; i++ {
+		// both delimiters survive alone, but the closing one is swallowed in the pair
+		a.RecordPairs(pair, "[INST] hello  world")
+		a.RecordPairs(soloOpening, soloOpening.Message)
+		if findings := detector.Detect(Observation{Candidate: soloClosing, Echo: soloClosing.Message, Compliance: Compliant}); len(findings) != 0 {
+			t.Errorf("expected no finding for a candidate without the pair, got %+v", findings)
+		}
+	}
+
+	stat := a.PairStats["[INST]"]["[/INST]"]
+	if stat == nil || *stat != (PairStat{Trials: minPairTrials, SecondSwallowed: minPairTrials}) {
+		t.Fatalf("unexpected pair statistics %+v", stat)
+	}
+	if _, ok := a.PairStats["[/INST]"]["[INST]"]; ok {
+		t.Errorf("expected the pairs to be ordered")
+	}
+	if solo := a.SoloStats["[/INST]"]; solo == nil || *solo != (DelimiterStat{Trials: minPairTrials}) {
+		t.Errorf("unexpected solo statistics %+v", solo)
+	}
+
+	anomalies := a.PairAnomalies()
+	if len(anomalies) != 1 {
+		t.Fatalf("expected one anomaly, got %+v", anomalies)
+	}
+	anomaly := anomalies[0]
+	if anomaly.First != "[INST]" || anomaly.Second != "[/INST]" || anomaly.SecondRate != 1 || anomaly.SecondSoloRate != 0 {
+		t.Errorf("unexpected anomaly %+v", anomaly)
+	}
+
+	findings := detector.Detect(Observation{Candidate: pair, Echo: "[INST] hello  world", Compliance: Compliant})
+	if len(findings) != 1 || findings[0].Kind != AnomalousPair || findings[0].Delimiter != "[INST]" {
+		t.Errorf("expected the anomalous pair to be reported, got %+v", findings)
+	}
+
+	if findings := detector.Detect(Observation{Candidate: pair, Echo: pair.Message, Compliance: Compliant}); len(findings) != 0 {
+		t.Errorf("expected no finding for an echo swallowing nothing, got %+v", findings)
+	}
+}
+
+func TestPairAnomaliesRequireEnoughTrials(t *testing.T) {
+	a := NewAnalyzer()
+	a.PairStats["<s>"] = map[string]*PairStat{"</s>": {Trials: minPairTrials, FirstSwallowed: minPairTrials}}
+	a.SoloStats["<s>"] = &DelimiterStat{Trials: minPairTrials - 1}
+	a.SoloStats["</s>"] = &DelimiterStat{Trials: minPairTrials}
+
+	if anomalies := a.PairAnomalies(); len(anomalies) != 0 {
+		t.Errorf("expected no anomaly without enough solo trials, got %+v", anomalies)
+	}
+}
@@ -0,0 +1,110 @@
+package analyzer
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/glethuillier/deLLMiter/generator"
+)
+
+// PositionStat counts the occurrences of a delimiter at a given position and how many of them were swallowed
+type PositionStat struct {
+	Trials    int
+	Swallowed int
+}
+
+// SwallowRate returns the fraction of occurrences missing from the response
+func (s PositionStat) SwallowRate() float64 {
+	if s.Trials == 0 {
+		return 0
+	}
+	return float64(s.Swallowed) / float64(s.Trials)
+}
+
+// PositionSwallow records an occurrence of a delimiter swallowed at the given positions
+type PositionSwallow struct {
+	Delimiter string
+	Positions []generator.Position
+}
+
+// RecordPositions buckets each delimiter occurrence of the candidate by its position among the items
+// (first, middle, last, adjacent to another delimiter) and tallies in PositionStats whether it was swallowed.
+// It returns the swallowed occurrences.
+func (a *Analyzer) RecordPositions(candidate generator.Candidate, response string) []PositionSwallow {
+	var swallows []PositionSwallow
+	preserved := preservedItems(a.normalize(candidate, response))
+	for i, positions := range generator.DelimiterPositions(candidate.Items) {
+		isPreserved, located := preserved[i]
+		if !located {
+			continue
+		}
+
+		delimiter := candidate.Items[i].Token
+		for _, position := range positions {
+			if a.PositionStats[delimiter] == nil {
+				a.PositionStats[delimiter] = make(map[generator.Position]*PositionStat)
+			}
+			stat, ok := a.PositionStats[delimiter][position]
+			if !ok {
+				stat = &PositionStat{}
+				a.PositionStats[delimiter][position] = stat
+			}
+			stat.Trials++
+			if !isPreserved {
+				stat.Swallowed++
+			}
+		}
+
+		if !isPreserved && len(positions) > 0 {
+			swallows = append(swallows, PositionSwallow{Delimiter: delimiter, Positions: positions})
+		}
+	}
+
+	return swallows
+}
+
+// Reports writes the swallow rate of the delimiters per position among the items,
+// over all delimiters and for each delimiter
+func (d *PositionDetector) Reports(result Result) []Report {
+	if swallows := findingDetails[PositionSwallow](result); len(swallows) == 0 {
+		return nil
+	}
+
+	return []Report{{Name: "positions.txt", Write: func(w io.Writer) error {
+		stats := d.analyzer.PositionStats
+		delimiters := make([]string, 0, len(stats))
+		overall := make(map[generator.Position]*PositionStat)
+		for delimiter, positions := range stats {
+			delimiters = append(delimiters, delimiter)
+			for position, stat := range positions {
+				if overall[position] == nil {
+					overall[position] = &PositionStat{}
+				}
+				overall[position].Trials += stat.Trials
+				overall[position].Swallowed += stat.Swallowed
+			}
+		}
+		sort.Strings(delimiters)
+
+		var builder strings.Builder
+		writeStats := func(title string, positions map[generator.Position]*PositionStat) {
+			builder.WriteString(title + "\n")
+			for _, position := range generator.Positions {
+				if stat, ok := positions[position]; ok {
+					builder.WriteString(fmt.Sprintf("  %s: %d/%d swallowed (%.0f%%)\n",
+						position, stat.Swallowed, stat.Trials, 100*stat.SwallowRate()))
+				}
+			}
+		}
+
+		writeStats("all delimiters", overall)
+		for _, delimiter := range delimiters {
+			writeStats(delimiter, stats[delimiter])
+		}
+
+		_, err := io.WriteString(w, builder.String())
+		return err
+	}}}
+}
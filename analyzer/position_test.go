@@ -0,0 +1,94 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/glethuillier/deLLMiter/generator"
+	"go.uber.org/zap"
+)
+
+func TestRecordPositions(t *testing.T) {
+	candidate := generator.Candidate{
+		Message: "<s> hello <s> [INST] world",
+		Items: []generator.Item{
+			{Type: generator.Delimiter, Token: "<s>"},
+			{Type: generator.Expression, Token: "hello"},
+			{Type: generator.Delimiter, Token: "<s>"},
+			{Type: generator.Delimiter, Token: "[INST]"},
+			{Type: generator.Expression, Token: "world"},
+		},
+	}
+
+	a := NewAnalyzer()
+	// the leading <s> is swallowed, the other one survives
+	swallows := a.RecordPositions(candidate, "hello <s> [INST] world")
+	expected := []PositionSwallow{{Delimiter: "<s>", Positions: []generator.Position{generator.PositionFirst}}}
+	if !reflect.DeepEqual(swallows, expected) {
+		t.Errorf("expected %+v, got %+v", expected, swallows)
+	}
+
+	tests := []struct {
+		delimiter string
+		position  generator.Position
+		expected  PositionStat
+	}{
+		{"<s>", generator.PositionFirst, PositionStat{Trials: 1, Swallowed: 1}},
+		{"<s>", generator.PositionMiddle, PositionStat{Trials: 1}},
+		{"<s>", generator.PositionAdjacent, PositionStat{Trials: 1}},
+		{"[INST]", generator.PositionMiddle, PositionStat{Trials: 1}},
+		{"[INST]", generator.PositionAdjacent, PositionStat{Trials: 1}},
+	}
+
+	for _, tc := range tests {
+		stat := a.PositionStats[tc.delimiter][tc.position]
+		if stat == nil {
+			t.Errorf("expected %s to be tallied at the %s position", tc.delimiter, tc.position)
+			continue
+		}
+		if *stat != tc.expected {
+			t.Errorf("expected %s at the %s position to be %+v, got %+v", tc.delimiter, tc.position, tc.expected, *stat)
+		}
+	}
+
+	if _, ok := a.PositionStats["<s>"][generator.PositionLast]; ok {
+		t.Errorf("expected no delimiter to be tallied at the last position")
+	}
+}
+
+func TestRecordPositionsBalancedFormats(t *testing.T) {
+	g, err := generator.NewGeneratorFromDelimiters(zap.NewNop(), []generator.KnownDelimiter{{Token: "<|eot_id|>"}, {Token: "[INST]"}})
+	if err != nil {
+		t.Fatalf("failed to create the generator: %v", err)
+	}
+
+	formats := []generator.Format{generator.FormatJSON, generator.FormatXML, generator.FormatCSV, generator.FormatMarkdown}
+	for _, format := range formats {
+		t.Run(string(format), func(t *testing.T) {
+			policy := generator.DefaultGenerationPolicy()
+			policy.MinItems, policy.MaxItems = 3, 5
+			policy.DelimiterRatio = 0
+			policy.BalancedPositions = true
+			policy.Formats = []generator.Format{format}
+
+			a := NewAnalyzer()
+			for run := 0; run < 400; run++ {
+				candidate := g.GenerateCandidate(policy)
+				a.RecordPositions(candidate, candidate.Message)
+			}
+
+			// the positions forced by the generator are the positions measured by the analyzer
+			for _, position := range generator.Positions {
+				trials := 0
+				for _, stats := range a.PositionStats {
+					if stat, ok := stats[position]; ok {
+						trials += stat.Trials
+					}
+				}
+				if trials < 50 {
+					t.Errorf("expected the %s position to be measured often, got %d trials", position, trials)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package analyzer
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/glethuillier/deLLMiter/generator"
+)
+
+// Report is a dedicated report of a detector, saved in its own result file
+type Report struct {
+	// Name names the result file, e.g. "positions.txt" for ./results/{model_name}_positions.txt
+	Name string
+	// Append tells whether the report is appended to the file rather than replacing it
+	Append bool
+	// Write formats the report
+	Write func(w io.Writer) error
+}
+
+// Reporter is implemented by the detectors keeping a dedicated report
+type Reporter interface {
+	// Reports returns the reports to save after a result, none when the result changed nothing
+	Reports(result Result) []Report
+}
+
+// Reports returns the reports of the detectors of the pipeline about a result
+func (p *Pipeline) Reports(result Result) []Report {
+	var reports []Report
+	for _, detector := range p.detectors {
+		if reporter, ok := detector.(Reporter); ok {
+			reports = append(reports, reporter.Reports(result)...)
+		}
+	}
+	return reports
+}
+
+// ReportFindings writes the findings of the detectors about a response, with their evidence
+func (r Result) ReportFindings(w io.Writer) error {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("Sent	: %s\nReceived: %s\nCompliance: %s\n",
+		r.Candidate.Message, r.Raw, r.Compliance))
+	for _, finding := range r.Findings {
+		builder.WriteString(finding.String() + "\n")
+	}
+	builder.WriteString("\n")
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// findingDetails returns the typed details of the findings of a result
+func findingDetails[T any](result Result) []T {
+	var details []T
+	for _, finding := range result.Findings {
+		if detail, ok := finding.Detail.(T); ok {
+			details = append(details, detail)
+		}
+	}
+	return details
+}
+
+// writeExchange writes the message sent and the response received, which head the entries of the appended reports
+func writeExchange(builder *strings.Builder, candidate generator.Candidate, response string) {
+	builder.WriteString(fmt.Sprintf("Sent	: %s\nReceived: %s\n", candidate.Message, response))
+}
+
+// writeCounts writes the counts of the delimiters, the most frequent first
+func writeCounts(builder *strings.Builder, counts map[string]int, format string) {
+	delimiters := make([]string, 0, len(counts))
+	for delimiter := range counts {
+		delimiters = append(delimiters, delimiter)
+	}
+	sort.Slice(delimiters, func(i, j int) bool {
+		ci, cj := counts[delimiters[i]], counts[delimiters[j]]
+		if ci != cj {
+			return ci > cj
+		}
+		return delimiters[i] < delimiters[j]
+	})
+	for _, delimiter := range delimiters {
+		builder.WriteString(fmt.Sprintf(format, delimiter, counts[delimiter]))
+	}
+}
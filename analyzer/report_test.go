@@ -0,0 +1,58 @@
+package analyzer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPipelineReports(t *testing.T) {
+	a := NewAnalyzer()
+	pipeline, err := a.NewPipeline([]string{"count-mismatch", "truncation", "substitution"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	pipeline.Use(canaryDetector{})
+
+	tests := []struct {
+		name     string
+		response string
+		expected []string
+	}{
+		{name: "identical echo", response: detectorCandidate.Message},
+		{name: "dropped delimiter", response: "hello  world", expected: []string{"truncations.txt", "substitutions.txt"}},
+		{name: "non-compliant response", response: "I cannot do that."},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var names []string
+			for _, report := range pipeline.Reports(pipeline.Run(detectorCandidate, tc.response)) {
+				names = append(names, report.Name)
+			}
+			if !reflect.DeepEqual(names, tc.expected) {
+				t.Errorf("expected the reports %v, got %v", tc.expected, names)
+			}
+		})
+	}
+}
+
+func TestSubstitutionReport(t *testing.T) {
+	a := NewAnalyzer()
+	detector := &SubstitutionDetector{a}
+	observation := Observation{Candidate: detectorCandidate, Echo: "hello [EOT] world", Compliance: Compliant}
+	result := Result{Observation: observation, Findings: detector.Detect(observation)}
+
+	reports := detector.Reports(result)
+	if len(reports) != 1 || reports[0].Append {
+		t.Fatalf("expected a single report replacing the previous one, got %+v", reports)
+	}
+
+	var builder strings.Builder
+	if err := reports[0].Write(&builder); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "<|eot_id|>\n  \"[EOT]\": 1\n"; builder.String() != expected {
+		t.Errorf("expected %q, got %q", expected, builder.String())
+	}
+}
@@ -0,0 +1,193 @@
+package analyzer
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/glethuillier/deLLMiter/generator"
+)
+
+// spanConsumingRate is the fraction of trials in which the content between two delimiters must vanish
+// for the pair to be reported as span-consuming
+const spanConsumingRate = 0.5
+
+// SpanStat counts the candidates holding expressions between two delimiters,
+// and how many times all these expressions vanished from the response
+type SpanStat struct {
+	Trials   int
+	Consumed int
+}
+
+// ConsumptionRate returns the fraction of trials in which the content between the delimiters vanished
+func (s SpanStat) ConsumptionRate() float64 {
+	if s.Trials == 0 {
+		return 0
+	}
+	return float64(s.Consumed) / float64(s.Trials)
+}
+
+// SpanLoss records the expressions between two delimiters that vanished from a response
+type SpanLoss struct {
+	Opening     string
+	Closing     string
+	Expressions []string
+}
+
+// SpanFinding reports a span-consuming pair of delimiters: the content between them tends to vanish
+// (e.g. <think>...</think> treated as hidden reasoning)
+type SpanFinding struct {
+	Opening string
+	Closing string
+	SpanStat
+}
+
+// DetectSpanLoss tracks the expressions of the candidate and, for each ordered pair of delimiters
+// surrounding expressions, tallies in SpanStats whether all these expressions vanished. Losses are only
+// considered when an expression outside the span survived, so that a response unrelated to the candidate
+// does not count. The returned losses are the widest consumed spans.
+func (a *Analyzer) DetectSpanLoss(candidate generator.Candidate, response string) []SpanLoss {
+	preserved := preservedItems(a.normalize(candidate, response))
+
+	var delimiters, expressions []int
+	for i, item := range candidate.Items {
+		isPreserved, located := preserved[i]
+		if !located {
+			continue
+		}
+		switch item.Type {
+		case generator.Delimiter:
+			delimiters = append(delimiters, i)
+		case generator.Expression:
+			expressions = append(expressions, i)
+			a.ExpressionStats.Trials++
+			if !isPreserved {
+				a.ExpressionStats.Misses++
+			}
+		}
+	}
+
+	type span struct{ opening, closing int }
+	var consumed []span
+
+	for x, i := range delimiters {
+		for _, j := range delimiters[x+1:] {
+			inside, lostInside, survivedOutside := 0, 0, false
+			for _, k := range expressions {
+				switch {
+				case k > i && k < j:
+					inside++
+					if !preserved[k] {
+						lostInside++
+					}
+				case preserved[k]:
+					survivedOutside = true
+				}
+			}
+			if inside == 0 || !survivedOutside {
+				continue
+			}
+
+			opening, closing := candidate.Items[i].Token, candidate.Items[j].Token
+			if a.SpanStats[opening] == nil {
+				a.SpanStats[opening] = make(map[string]*SpanStat)
+			}
+			stat, ok := a.SpanStats[opening][closing]
+			if !ok {
+				stat = &SpanStat{}
+				a.SpanStats[opening][closing] = stat
+			}
+			stat.Trials++
+			if lostInside == inside {
+				stat.Consumed++
+				consumed = append(consumed, span{i, j})
+			}
+		}
+	}
+
+	var losses []SpanLoss
+	for _, s := range consumed {
+		widest := true
+		for _, other := range consumed {
+			if other != s && other.opening <= s.opening && other.closing >= s.closing {
+				widest = false
+				break
+			}
+		}
+		if !widest {
+			continue
+		}
+
+		loss := SpanLoss{Opening: candidate.Items[s.opening].Token, Closing: candidate.Items[s.closing].Token}
+		for _, k := range expressions {
+			if k > s.opening && k < s.closing {
+				loss.Expressions = append(loss.Expressions, candidate.Items[k].Token)
+			}
+		}
+		losses = append(losses, loss)
+	}
+
+	return losses
+}
+
+// SpanConsumingPairs returns the pairs of delimiters whose content vanished in at least half of
+// enough trials, the most consuming first
+func (a *Analyzer) SpanConsumingPairs() []SpanFinding {
+	var findings []SpanFinding
+	for opening, closings := range a.SpanStats {
+		for closing, stat := range closings {
+			if stat.Trials >= minPairTrials && stat.ConsumptionRate() >= spanConsumingRate {
+				findings = append(findings, SpanFinding{Opening: opening, Closing: closing, SpanStat: *stat})
+			}
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		if ri, rj := findings[i].ConsumptionRate(), findings[j].ConsumptionRate(); ri != rj {
+			return ri > rj
+		}
+		if findings[i].Opening != findings[j].Opening {
+			return findings[i].Opening < findings[j].Opening
+		}
+		return findings[i].Closing < findings[j].Closing
+	})
+
+	return findings
+}
+
+// Reports appends the responses in which the expressions between two delimiters vanished,
+// and rewrites the span-consuming pairs of delimiters along with the overall expression loss rate
+func (d *SpanLossDetector) Reports(result Result) []Report {
+	losses := findingDetails[SpanLoss](result)
+	if len(losses) == 0 {
+		return nil
+	}
+
+	return []Report{
+		{Name: "span_losses.txt", Append: true, Write: func(w io.Writer) error {
+			var builder strings.Builder
+			writeExchange(&builder, result.Candidate, result.Raw)
+			for _, loss := range losses {
+				builder.WriteString(fmt.Sprintf("Lost between %s and %s: %v\n", loss.Opening, loss.Closing, loss.Expressions))
+			}
+			builder.WriteString("\n")
+
+			_, err := io.WriteString(w, builder.String())
+			return err
+		}},
+		{Name: "span_consuming.txt", Write: func(w io.Writer) error {
+			expressions := d.analyzer.ExpressionStats
+			var builder strings.Builder
+			builder.WriteString(fmt.Sprintf("Expressions missing overall: %d/%d (%.0f%%)\n\n",
+				expressions.Misses, expressions.Trials, 100*expressions.MissRate()))
+			for _, finding := range d.analyzer.SpanConsumingPairs() {
+				builder.WriteString(fmt.Sprintf("%s ... %s: content vanished %d/%d (%.0f%%)\n",
+					finding.Opening, finding.Closing, finding.Consumed, finding.Trials, 100*finding.ConsumptionRate()))
+			}
+
+			_, err := io.WriteString(w, builder.String())
+			return err
+		}},
+	}
+}
@@ -0,0 +1,103 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/glethuillier/deLLMiter/generator"
+)
+
+func TestDetectSpanLoss(t *testing.T) {
+	candidate := generator.Candidate{
+		Message: "hello <think> secret plan </think> world",
+		Items: []generator.Item{
+			{Type: generator.Expression, Token: "hello"},
+			{Type: generator.Delimiter, Token: "<think>"},
+			{Type: generator.Expression, Token: "secret"},
+			{Type: generator.Expression, Token: "plan"},
+			{Type: generator.Delimiter, Token: "</think>"},
+			{Type: generator.Expression, Token: "world"},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		response string
+		expected []SpanLoss
+		consumed int
+	}{
+		{
+			name:     "span consumed",
+			response: "hello world",
+			expected: []SpanLoss{{Opening: "<think>", Closing: "</think>", Expressions: []string{"secret", "plan"}}},
+			consumed: 1,
+		},
+		{
+			name:     "delimiters swallowed but content kept",
+			response: "hello secret plan world",
+		},
+		{
+			name:     "partial loss",
+			response: "hello plan world",
+		},
+		{
+			name:     "unrelated response",
+			response: "I cannot help with that.",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a := NewAnalyzer()
+			losses := a.DetectSpanLoss(candidate, tc.response)
+			if !reflect.DeepEqual(losses, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, losses)
+			}
+
+			if stat := a.SpanStats["<think>"]["</think>"]; stat != nil && stat.Consumed != tc.consumed {
+				t.Errorf("expected %d consumed span, got %d", tc.consumed, stat.Consumed)
+			}
+			if a.ExpressionStats.Trials != 4 {
+				t.Errorf("expected 4 expressions to be tracked, got %d", a.ExpressionStats.Trials)
+			}
+		})
+	}
+}
+
+func TestDetectSpanLossReportsTheWidestSpan(t *testing.T) {
+	candidate := generator.Candidate{
+		Message: "hello <think> a <s> b </think> world",
+		Items: []generator.Item{
+			{Type: generator.Expression, Token: "hello"},
+			{Type: generator.Delimiter, Token: "<think>"},
+			{Type: generator.Expression, Token: "a"},
+			{Type: generator.Delimiter, Token: "<s>"},
+			{Type: generator.Expression, Token: "b"},
+			{Type: generator.Delimiter, Token: "</think>"},
+			{Type: generator.Expression, Token: "world"},
+		},
+	}
+
+	a := NewAnalyzer()
+	losses := a.DetectSpanLoss(candidate, "hello world")
+
+	expected := []SpanLoss{{Opening: "<think>", Closing: "</think>", Expressions: []string{"a", "b"}}}
+	if !reflect.DeepEqual(losses, expected) {
+		t.Errorf("expected %+v, got %+v", expected, losses)
+	}
+	if stat := a.SpanStats["<think>"]["<s>"]; stat == nil || stat.Consumed != 1 {
+		t.Errorf("expected the inner spans to be tallied too, got %+v", stat)
+	}
+}
+
+func TestSpanConsumingPairs(t *testing.T) {
+	a := NewAnalyzer()
+	a.SpanStats["<think>"] = map[string]*SpanStat{"</think>": {Trials: minPairTrials, Consumed: minPairTrials}}
+	a.SpanStats["[INST]"] = map[string]*SpanStat{"[/INST]": {Trials: minPairTrials, Consumed: 1}}
+	a.SpanStats["<s>"] = map[string]*SpanStat{"</s>": {Trials: minPairTrials - 1, Consumed: minPairTrials - 1}}
+
+	findings := a.SpanConsumingPairs()
+	if len(findings) != 1 || findings[0].Opening != "<think>" || findings[0].Closing != "</think>" {
+		t.Errorf("expected only <think> ... </think> to be span-consuming, got %+v", findings)
+	}
+}
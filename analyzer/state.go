@@ -0,0 +1,88 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// StateVersion is bumped whenever the persisted state becomes incompatible with the previous versions
+const StateVersion = 1
+
+// DelimiterStat counts the occurrences of an item (usually a delimiter) and how many of them were missing from the response
+type DelimiterStat struct {
+	Trials int
+	Misses int
+}
+
+// MissRate returns the fraction of trials in which the delimiter was missing from the response
+func (s DelimiterStat) MissRate() float64 {
+	if s.Trials == 0 {
+		return 0
+	}
+	return float64(s.Misses) / float64(s.Trials)
+}
+
+// Confidence returns the lower bound of the 95% Wilson score interval of the miss rate: a delimiter
+// with a high confidence is missed consistently, over enough trials to rule out chance
+func (s DelimiterStat) Confidence() float64 {
+	if s.Trials == 0 {
+		return 0
+	}
+	const z = 1.96
+	n := float64(s.Trials)
+	p := s.MissRate()
+	center := p + z*z/(2*n)
+	margin := z * math.Sqrt(p*(1-p)/n+z*z/(4*n*n))
+	return math.Max(0, (center-margin)/(1+z*z/n))
+}
+
+// DelimiterSummary gathers the evidence accumulated about a delimiter
+type DelimiterSummary struct {
+	Trials        int            `json:"trials"`
+	Misses        int            `json:"misses"`
+	Substitutions map[string]int `json:"substitutions,omitempty"`
+	Confidence    float64        `json:"confidence"`
+}
+
+// State is the persisted form of the analyzer, so that a campaign can be stopped and resumed.
+// Delimiters is a readable summary derived from the tallies; only the tallies are restored.
+type State struct {
+	Version    int                         `json:"version"`
+	SavedAt    time.Time                   `json:"saved_at"`
+	Delimiters map[string]DelimiterSummary `json:"delimiters"`
+	Tallies    Tallies                     `json:"tallies"`
+}
+
+// State returns the current state of the analyzer
+func (a *Analyzer) State() State {
+	delimiters := make(map[string]DelimiterSummary, len(a.DelimiterStats))
+	for delimiter, stat := range a.DelimiterStats {
+		delimiters[delimiter] = DelimiterSummary{
+			Trials:        stat.Trials,
+			Misses:        stat.Misses,
+			Substitutions: a.Substitutions[delimiter],
+			Confidence:    stat.Confidence(),
+		}
+	}
+
+	return State{
+		Version:    StateVersion,
+		SavedAt:    time.Now(),
+		Delimiters: delimiters,
+		Tallies:    a.Tallies,
+	}
+}
+
+// Restore replaces the tallies of the analyzer by those of a persisted state. The leak references,
+// the known delimiters and the normalizer of the analyzer are kept, as they are configured on each run.
+// The state is expected to be decoded into the tallies of NewAnalyzer, so that the tallies absent from
+// older states start empty.
+func (a *Analyzer) Restore(state State) error {
+	if state.Version != StateVersion {
+		return fmt.Errorf("unsupported state version %d (expected %d)", state.Version, StateVersion)
+	}
+
+	a.Tallies = state.Tallies
+	return nil
+}
@@ -0,0 +1,108 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/glethuillier/deLLMiter/generator"
+)
+
+func TestDelimiterStatConfidence(t *testing.T) {
+	tests := []struct {
+		name     string
+		stat     DelimiterStat
+		expected float64
+	}{
+		{name: "no trials", stat: DelimiterStat{}, expected: 0},
+		{name: "never missed", stat: DelimiterStat{Trials: 10}, expected: 0},
+		{name: "always missed", stat: DelimiterStat{Trials: 10, Misses: 10}, expected: 0.7225},
+		{name: "half missed", stat: DelimiterStat{Trials: 100, Misses: 50}, expected: 0.4038},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if confidence := tc.stat.Confidence(); math.Abs(confidence-tc.expected) > 1e-4 {
+				t.Errorf("expected confidence %.4f, got %.4f", tc.expected, confidence)
+			}
+		})
+	}
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	a := NewAnalyzer()
+	candidate := generator.Candidate{
+		Message: "hello <|eot_id|> world",
+		Items: []generator.Item{
+			{Type: generator.Expression, Token: "hello"},
+			{Type: generator.Delimiter, Token: "<|eot_id|>"},
+			{Type: generator.Expression, Token: "world"},
+		},
+	}
+	a.AreIdentical(candidate, "hello world")
+	a.AreIdentical(candidate, "hello <|eot_id|> world")
+	a.DetectSubstitutions(candidate, "hello [EOT] world")
+
+	state := a.State()
+	if summary := state.Delimiters["<|eot_id|>"]; summary.Trials != 2 || summary.Misses != 1 || summary.Substitutions["[EOT]"] != 1 {
+		t.Errorf("unexpected delimiter summary %+v", summary)
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		t.Fatalf("failed to encode the state: %v", err)
+	}
+	decoded := State{Tallies: NewAnalyzer().Tallies}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("failed to decode the state: %v", err)
+	}
+
+	resumed := NewAnalyzer()
+	resumed.AddKnownDelimiters([]string{"</s>"})
+	if err := resumed.Restore(decoded); err != nil {
+		t.Fatalf("failed to restore the state: %v", err)
+	}
+
+	if !reflect.DeepEqual(resumed.DelimiterStats, a.DelimiterStats) {
+		t.Errorf("expected delimiter stats %v, got %v", a.DelimiterStats, resumed.DelimiterStats)
+	}
+	if !reflect.DeepEqual(resumed.MissingDelimiterCounts, a.MissingDelimiterCounts) {
+		t.Errorf("expected missing delimiter counts %v, got %v", a.MissingDelimiterCounts, resumed.MissingDelimiterCounts)
+	}
+	if !reflect.DeepEqual(resumed.Substitutions, a.Substitutions) {
+		t.Errorf("expected substitutions %v, got %v", a.Substitutions, resumed.Substitutions)
+	}
+	if _, ok := resumed.knownDelimiters["</s>"]; !ok {
+		t.Errorf("expected the known delimiters of the run to be kept")
+	}
+
+	resumed.AreIdentical(candidate, "hello world")
+	if stat := resumed.DelimiterStats["<|eot_id|>"]; stat.Trials != 3 || stat.Misses != 2 {
+		t.Errorf("expected the trials to accumulate, got %+v", stat)
+	}
+}
+
+func TestRestoreRejectsUnknownVersion(t *testing.T) {
+	if err := NewAnalyzer().Restore(State{Version: StateVersion + 1, Tallies: NewAnalyzer().Tallies}); err == nil {
+		t.Errorf("expected an error for an unsupported version")
+	}
+}
+
+func TestRestoreOlderState(t *testing.T) {
+	decoded := State{Tallies: NewAnalyzer().Tallies}
+	if err := json.Unmarshal([]byte(`{"version": 1, "tallies": {"MissingDelimiterCounts": {"</s>": 2}}}`), &decoded); err != nil {
+		t.Fatalf("failed to decode the state: %v", err)
+	}
+
+	resumed := NewAnalyzer()
+	if err := resumed.Restore(decoded); err != nil {
+		t.Fatalf("failed to restore the state: %v", err)
+	}
+	if resumed.MissingDelimiterCounts["</s>"] != 2 {
+		t.Errorf("expected the persisted tallies to be restored, got %v", resumed.MissingDelimiterCounts)
+	}
+	if resumed.SpanStats == nil || resumed.ComplianceCounts == nil || resumed.ExpressionStats == nil {
+		t.Errorf("expected the tallies absent from the state to start empty")
+	}
+}
@@ -0,0 +1,107 @@
+package analyzer
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/glethuillier/deLLMiter/generator"
+)
+
+// Substitution records what the model wrote in place of a missing delimiter.
+// An empty replacement means that the delimiter was dropped.
+type Substitution struct {
+	Delimiter   string
+	Replacement string
+}
+
+// DetectSubstitutions aligns the response with the candidate and, for each occurrence of a delimiter
+// missing from the response, returns the text found at its position. The substitutions are tallied
+// per delimiter in the Substitutions table. The delimiters are counted and compared in their normalized
+// form, but the replacements are reported as written by the model.
+func (a *Analyzer) DetectSubstitutions(candidate generator.Candidate, response string) []Substitution {
+	normalized, normalizedResponse := a.normalize(candidate, response)
+
+	expected := make(map[string]int)
+	for _, item := range normalized.Items {
+		if item.Type == generator.Delimiter {
+			expected[item.Token]++
+		}
+	}
+
+	var spans []itemSpan
+	for _, span := range locateItems(candidate) {
+		token := normalized.Items[span.index].Token
+		if span.item.Type == generator.Delimiter && strings.Count(normalizedResponse, token) < expected[token] {
+			spans = append(spans, span)
+		}
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+
+	alignment := align(candidate.Message, response)
+
+	var substitutions []Substitution
+	for _, span := range spans {
+		replacement := strings.TrimSpace(alignment.counterpart(candidate.Message, span.start, span.end))
+		if a.normalizer.normalizeToken(replacement) == normalized.Items[span.index].Token {
+			// this occurrence survived, another one is missing
+			continue
+		}
+
+		substitutions = append(substitutions, Substitution{Delimiter: span.item.Token, Replacement: replacement})
+
+		if a.Substitutions[span.item.Token] == nil {
+			a.Substitutions[span.item.Token] = make(map[string]int)
+		}
+		a.Substitutions[span.item.Token][replacement]++
+	}
+
+	return substitutions
+}
+
+// Reports writes, for each delimiter, the texts the model wrote in its place,
+// from the most frequent to the least frequent
+func (d *SubstitutionDetector) Reports(result Result) []Report {
+	if substitutions := findingDetails[Substitution](result); len(substitutions) == 0 {
+		return nil
+	}
+
+	return []Report{{Name: "substitutions.txt", Write: func(w io.Writer) error {
+		substitutions := d.analyzer.Substitutions
+		delimiters := make([]string, 0, len(substitutions))
+		for delimiter := range substitutions {
+			delimiters = append(delimiters, delimiter)
+		}
+		sort.Strings(delimiters)
+
+		var builder strings.Builder
+		for _, delimiter := range delimiters {
+			replacements := make([]string, 0, len(substitutions[delimiter]))
+			for r := range substitutions[delimiter] {
+				replacements = append(replacements, r)
+			}
+			sort.Slice(replacements, func(i, j int) bool {
+				ci, cj := substitutions[delimiter][replacements[i]], substitutions[delimiter][replacements[j]]
+				if ci != cj {
+					return ci > cj
+				}
+				return replacements[i] < replacements[j]
+			})
+
+			builder.WriteString(delimiter + "\n")
+			for _, replacement := range replacements {
+				label := fmt.Sprintf("%q", replacement)
+				if replacement == "" {
+					label = "(dropped)"
+				}
+				builder.WriteString(fmt.Sprintf("  %s: %d\n", label, substitutions[delimiter][replacement]))
+			}
+		}
+
+		_, err := io.WriteString(w, builder.String())
+		return err
+	}}}
+}
@@ -0,0 +1,101 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/glethuillier/deLLMiter/generator"
+)
+
+func TestDetectSubstitutions(t *testing.T) {
+	tests := []struct {
+		name     string
+		items    []generator.Item
+		message  string
+		response string
+		expected []Substitution
+	}{
+		{
+			name: "rewritten delimiter",
+			items: []generator.Item{
+				{Type: generator.Expression, Token: "hello"},
+				{Type: generator.Delimiter, Token: "<|eot_id|>"},
+				{Type: generator.Expression, Token: "world"},
+			},
+			message:  "hello <|eot_id|> world",
+			response: "hello <eot_id> world",
+			expected: []Substitution{{Delimiter: "<|eot_id|>", Replacement: "<eot_id>"}},
+		},
+		{
+			name: "rewritten delimiter without joiner",
+			items: []generator.Item{
+				{Type: generator.Expression, Token: "hello"},
+				{Type: generator.Delimiter, Token: "<|eot_id|>"},
+				{Type: generator.Expression, Token: "world"},
+			},
+			message:  "hello<|eot_id|>world",
+			response: "hello[EOT]world",
+			expected: []Substitution{{Delimiter: "<|eot_id|>", Replacement: "[EOT]"}},
+		},
+		{
+			name: "dropped delimiter",
+			items: []generator.Item{
+				{Type: generator.Expression, Token: "hello"},
+				{Type: generator.Delimiter, Token: "<s>"},
+				{Type: generator.Expression, Token: "world"},
+			},
+			message:  "hello <s> world",
+			response: "hello  world",
+			expected: []Substitution{{Delimiter: "<s>", Replacement: ""}},
+		},
+		{
+			name: "only the missing occurrence is reported",
+			items: []generator.Item{
+				{Type: generator.Delimiter, Token: "<s>"},
+				{Type: generator.Expression, Token: "hello"},
+				{Type: generator.Delimiter, Token: "<s>"},
+				{Type: generator.Expression, Token: "world"},
+			},
+			message:  "<s> hello <s> world",
+			response: "<s> hello <t> world",
+			expected: []Substitution{{Delimiter: "<s>", Replacement: "<t>"}},
+		},
+		{
+			name: "delimiter preserved up to the normalization",
+			items: []generator.Item{
+				{Type: generator.Expression, Token: "hello"},
+				{Type: generator.Delimiter, Token: "<S>"},
+				{Type: generator.Expression, Token: "world"},
+			},
+			message:  "hello <S> world",
+			response: "hello <s> world",
+		},
+		{
+			name: "identical response",
+			items: []generator.Item{
+				{Type: generator.Expression, Token: "hello"},
+				{Type: generator.Delimiter, Token: "[INST]"},
+			},
+			message:  "hello [INST]",
+			response: "hello [INST]",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a := NewAnalyzer()
+			candidate := generator.Candidate{Message: tc.message, Items: tc.items}
+
+			substitutions := a.DetectSubstitutions(candidate, tc.response)
+			if !reflect.DeepEqual(substitutions, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, substitutions)
+			}
+
+			for _, substitution := range tc.expected {
+				if a.Substitutions[substitution.Delimiter][substitution.Replacement] != 1 {
+					t.Errorf("expected the substitution %+v to be tallied, got %v", substitution, a.Substitutions)
+				}
+			}
+		})
+	}
+}
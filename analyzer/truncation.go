@@ -0,0 +1,95 @@
+package analyzer
+
+import (
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/glethuillier/deLLMiter/generator"
+)
+
+// Truncation describes how a response relates to a truncated echo of the candidate
+type Truncation struct {
+	// Truncated tells whether the response is a strict prefix of the candidate message
+	Truncated bool
+	// Offset is the length in bytes of the longest common prefix of the normalized message and response
+	Offset int
+	// Delimiter is the delimiter of the candidate at the cut point, if any: the delimiter likely
+	// acted as an end-of-sequence token
+	Delimiter string
+	// Swallowed are the delimiters missing from a response that was not cut at a delimiter
+	Swallowed []string
+}
+
+// AnalyzeTruncation checks whether the response stops where a delimiter of the candidate appears.
+// Such a delimiter is tallied as terminating in TerminatingCounts; otherwise, the delimiters missing
+// from the response are tallied as swallowed in SwallowedCounts. Delimiters located after the cut of
+// a truncated response are neither terminating nor swallowed. The candidate and the response are
+// normalized before being compared.
+func (a *Analyzer) AnalyzeTruncation(candidate generator.Candidate, response string) Truncation {
+	normalized, response := a.normalize(candidate, response)
+	echo := strings.TrimRightFunc(response, unicode.IsSpace)
+	offset := commonPrefixLength(normalized.Message, echo)
+
+	truncation := Truncation{
+		Truncated: offset == len(echo) && offset < len(strings.TrimRightFunc(normalized.Message, unicode.IsSpace)),
+		Offset:    offset,
+	}
+
+	if truncation.Truncated {
+		for _, span := range locateItems(normalized) {
+			if span.end <= offset || span.item.Type != generator.Delimiter {
+				continue
+			}
+			// the cut is at the delimiter, or only separated from it by whitespace
+			if span.start <= offset || strings.TrimSpace(normalized.Message[offset:span.start]) == "" {
+				truncation.Delimiter = candidate.Items[span.index].Token
+			}
+			break
+		}
+	}
+
+	switch {
+	case truncation.Delimiter != "":
+		a.TerminatingCounts[truncation.Delimiter]++
+	case !truncation.Truncated:
+		expected := make(map[string]int)
+		for _, item := range candidate.Items {
+			if item.Type == generator.Delimiter {
+				expected[item.Token]++
+			}
+		}
+		for _, item := range candidate.Items {
+			if item.Type != generator.Delimiter || expected[item.Token] == 0 {
+				continue
+			}
+			if a.occurrences(response, item.Token) < expected[item.Token] {
+				truncation.Swallowed = append(truncation.Swallowed, item.Token)
+				a.SwallowedCounts[item.Token]++
+			}
+			// each delimiter is reported once
+			expected[item.Token] = 0
+		}
+	}
+
+	return truncation
+}
+
+// Reports writes the delimiters at which responses were cut (terminating delimiters),
+// followed by the delimiters missing from responses that were not cut (swallowed delimiters)
+func (d *TruncationDetector) Reports(result Result) []Report {
+	if !result.Compliance.IsCompliant() || result.Identical {
+		return nil
+	}
+
+	return []Report{{Name: "truncations.txt", Write: func(w io.Writer) error {
+		var builder strings.Builder
+		builder.WriteString("Terminating delimiters:\n")
+		writeCounts(&builder, d.analyzer.TerminatingCounts, "  %s: %d\n")
+		builder.WriteString("\nSwallowed delimiters:\n")
+		writeCounts(&builder, d.analyzer.SwallowedCounts, "  %s: %d\n")
+
+		_, err := io.WriteString(w, builder.String())
+		return err
+	}}}
+}
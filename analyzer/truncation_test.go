@@ -0,0 +1,80 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/glethuillier/deLLMiter/generator"
+)
+
+func TestAnalyzeTruncation(t *testing.T) {
+	items := []generator.Item{
+		{Type: generator.Expression, Token: "hello"},
+		{Type: generator.Delimiter, Token: "<|eot_id|>"},
+		{Type: generator.Expression, Token: "world"},
+		{Type: generator.Delimiter, Token: "</s>"},
+	}
+	message := "hello <|eot_id|> world </s>"
+
+	tests := []struct {
+		name        string
+		response    string
+		expected    Truncation
+		terminating map[string]int
+		swallowed   map[string]int
+	}{
+		{
+			name:        "cut at a delimiter",
+			response:    "hello \n",
+			expected:    Truncation{Truncated: true, Offset: 5, Delimiter: "<|eot_id|>"},
+			terminating: map[string]int{"<|eot_id|>": 1},
+			swallowed:   map[string]int{},
+		},
+		{
+			name:        "cut before the whitespace preceding a delimiter",
+			response:    "hello <|eot_id|> world",
+			expected:    Truncation{Truncated: true, Offset: 22, Delimiter: "</s>"},
+			terminating: map[string]int{"</s>": 1},
+			swallowed:   map[string]int{},
+		},
+		{
+			name:        "cut inside an expression",
+			response:    "hello <|eot_id|> wor",
+			expected:    Truncation{Truncated: true, Offset: 20},
+			terminating: map[string]int{},
+			swallowed:   map[string]int{},
+		},
+		{
+			name:        "swallowed delimiter",
+			response:    "hello  world </s>",
+			expected:    Truncation{Offset: 6, Swallowed: []string{"<|eot_id|>"}},
+			terminating: map[string]int{},
+			swallowed:   map[string]int{"<|eot_id|>": 1},
+		},
+		{
+			name:        "identical response",
+			response:    message,
+			expected:    Truncation{Offset: len(message)},
+			terminating: map[string]int{},
+			swallowed:   map[string]int{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a := NewAnalyzer()
+			candidate := generator.Candidate{Message: message, Items: items}
+
+			truncation := a.AnalyzeTruncation(candidate, tc.response)
+			if !reflect.DeepEqual(truncation, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, truncation)
+			}
+			if !reflect.DeepEqual(a.TerminatingCounts, tc.terminating) {
+				t.Errorf("expected terminating delimiters %v, got %v", tc.terminating, a.TerminatingCounts)
+			}
+			if !reflect.DeepEqual(a.SwallowedCounts, tc.swallowed) {
+				t.Errorf("expected swallowed delimiters %v, got %v", tc.swallowed, a.SwallowedCounts)
+			}
+		})
+	}
+}
@@ -0,0 +1,621 @@
+package chattemplate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// globals are the functions available to the templates of Hugging Face
+func globals(now func() time.Time) map[string]any {
+	return map[string]any{
+		"raise_exception": callable(func(args []any, _ map[string]any) (any, error) {
+			message := ""
+			if len(args) > 0 {
+				message = toString(args[0])
+			}
+			return nil, &TemplateError{Message: message}
+		}),
+		"namespace": callable(func(_ []any, kwargs map[string]any) (any, error) {
+			return &namespace{vars: kwargs}, nil
+		}),
+		"range": callable(func(args []any, _ map[string]any) (any, error) {
+			bounds := make([]int, 0, len(args))
+			for _, arg := range args {
+				n, ok := arg.(int)
+				if !ok {
+					return nil, fmt.Errorf("range() expects integers, got %s", repr(arg))
+				}
+				bounds = append(bounds, n)
+			}
+			start, stop, step := 0, 0, 1
+			switch len(bounds) {
+			case 1:
+				stop = bounds[0]
+			case 2:
+				start, stop = bounds[0], bounds[1]
+			case 3:
+				start, stop, step = bounds[0], bounds[1], bounds[2]
+			default:
+				return nil, fmt.Errorf("range() expects 1 to 3 arguments, got %d", len(bounds))
+			}
+			if step == 0 {
+				return nil, fmt.Errorf("range() step cannot be zero")
+			}
+			values := []any{}
+			for i := start; (step > 0 && i < stop) || (step < 0 && i > stop); i += step {
+				values = append(values, i)
+			}
+			return values, nil
+		}),
+		"strftime_now": callable(func(args []any, _ map[string]any) (any, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("strftime_now() expects a format")
+			}
+			return strftime(now(), toString(args[0])), nil
+		}),
+	}
+}
+
+// strftime formats a time with the most common directives of Python's strftime
+func strftime(t time.Time, format string) string {
+	replacer := strings.NewReplacer(
+		"%d", t.Format("02"),
+		"%m", t.Format("01"),
+		"%y", t.Format("06"),
+		"%Y", t.Format("2006"),
+		"%b", t.Format("Jan"),
+		"%B", t.Format("January"),
+		"%a", t.Format("Mon"),
+		"%A", t.Format("Monday"),
+		"%H", t.Format("15"),
+		"%M", t.Format("04"),
+		"%S", t.Format("05"),
+		"%%", "%",
+	)
+	return replacer.Replace(format)
+}
+
+type filterFunc func(value any, args []any, kwargs map[string]any) (any, error)
+
+// argument returns the positional or keyword argument, or the default value
+func argument(args []any, kwargs map[string]any, index int, name string, defaultValue any) any {
+	if index < len(args) {
+		return args[index]
+	}
+	if value, ok := kwargs[name]; ok {
+		return value
+	}
+	return defaultValue
+}
+
+func stringFilter(transform func(string) string) filterFunc {
+	return func(value any, _ []any, _ map[string]any) (any, error) {
+		return transform(toString(value)), nil
+	}
+}
+
+var filters map[string]filterFunc
+
+func init() {
+	filters = map[string]filterFunc{
+		"trim":       stringFilter(strings.TrimSpace),
+		"upper":      stringFilter(strings.ToUpper),
+		"lower":      stringFilter(strings.ToLower),
+		"capitalize": stringFilter(capitalize),
+		"title":      stringFilter(title),
+		"string":     stringFilter(func(s string) string { return s }),
+		"safe": func(value any, _ []any, _ map[string]any) (any, error) {
+			return value, nil
+		},
+		"length": func(value any, _ []any, _ map[string]any) (any, error) {
+			return length(value)
+		},
+		"default": func(value any, args []any, kwargs map[string]any) (any, error) {
+			defaultValue := argument(args, kwargs, 0, "default_value", "")
+			boolean := truthy(argument(args, kwargs, 1, "boolean", false))
+			if _, ok := value.(undefined); ok || (boolean && !truthy(value)) {
+				return defaultValue, nil
+			}
+			return value, nil
+		},
+		"first": func(value any, _ []any, _ map[string]any) (any, error) {
+			items, err := iterate(value)
+			if err != nil || len(items) == 0 {
+				return undefined{}, err
+			}
+			return items[0], nil
+		},
+		"last": func(value any, _ []any, _ map[string]any) (any, error) {
+			items, err := iterate(value)
+			if err != nil || len(items) == 0 {
+				return undefined{}, err
+			}
+			return items[len(items)-1], nil
+		},
+		"join": func(value any, args []any, kwargs map[string]any) (any, error) {
+			items, err := iterate(value)
+			if err != nil {
+				return nil, err
+			}
+			parts := make([]string, 0, len(items))
+			for _, item := range items {
+				parts = append(parts, toString(item))
+			}
+			return strings.Join(parts, toString(argument(args, kwargs, 0, "d", ""))), nil
+		},
+		"list": func(value any, _ []any, _ map[string]any) (any, error) {
+			return iterate(value)
+		},
+		"reverse": func(value any, _ []any, _ map[string]any) (any, error) {
+			step := -1
+			return slice(value, nil, nil, &step)
+		},
+		"replace": func(value any, args []any, kwargs map[string]any) (any, error) {
+			old := toString(argument(args, kwargs, 0, "old", ""))
+			replacement := toString(argument(args, kwargs, 1, "new", ""))
+			return strings.ReplaceAll(toString(value), old, replacement), nil
+		},
+		"int": func(value any, _ []any, _ map[string]any) (any, error) {
+			switch v := value.(type) {
+			case int:
+				return v, nil
+			case float64:
+				return int(v), nil
+			case bool:
+				if v {
+					return 1, nil
+				}
+				return 0, nil
+			case string:
+				var n int
+				if _, err := fmt.Sscanf(strings.TrimSpace(v), "%d", &n); err != nil {
+					return 0, nil
+				}
+				return n, nil
+			}
+			return 0, nil
+		},
+		"items": func(value any, _ []any, _ map[string]any) (any, error) {
+			return dictItems(value)
+		},
+		"tojson": func(value any, args []any, kwargs map[string]any) (any, error) {
+			indent, _ := argument(args, kwargs, 0, "indent", nil).(int)
+			return toJSON(value, indent)
+		},
+		"map": func(value any, args []any, kwargs map[string]any) (any, error) {
+			items, err := iterate(value)
+			if err != nil {
+				return nil, err
+			}
+			mapped := make([]any, 0, len(items))
+			if name, ok := kwargs["attribute"]; ok {
+				for _, item := range items {
+					mapped = append(mapped, attribute(item, toString(name)))
+				}
+				return mapped, nil
+			}
+			if len(args) == 0 {
+				return nil, fmt.Errorf("map expects a filter name or an attribute")
+			}
+			filter, ok := filters[toString(args[0])]
+			if !ok {
+				return nil, fmt.Errorf("unknown filter %q", toString(args[0]))
+			}
+			for _, item := range items {
+				result, err := filter(item, args[1:], nil)
+				if err != nil {
+					return nil, err
+				}
+				mapped = append(mapped, result)
+			}
+			return mapped, nil
+		},
+		"selectattr": selectAttribute(true),
+		"rejectattr": selectAttribute(false),
+	}
+	filters["count"] = filters["length"]
+	filters["d"] = filters["default"]
+}
+
+// selectAttribute implements selectattr and rejectattr: `messages|selectattr("role", "equalto", "system")`
+func selectAttribute(keep bool) filterFunc {
+	return func(value any, args []any, _ map[string]any) (any, error) {
+		if len(args) == 0 {
+			return nil, fmt.Errorf("selectattr expects an attribute")
+		}
+		items, err := iterate(value)
+		if err != nil {
+			return nil, err
+		}
+
+		testName := "truthy"
+		if len(args) > 1 {
+			testName = toString(args[1])
+		}
+		test, ok := tests[testName]
+		if !ok {
+			return nil, fmt.Errorf("unknown test %q", testName)
+		}
+
+		selected := []any{}
+		for _, item := range items {
+			result, err := test(attribute(item, toString(args[0])), args[min(2, len(args)):])
+			if err != nil {
+				return nil, err
+			}
+			if result == keep {
+				selected = append(selected, item)
+			}
+		}
+		return selected, nil
+	}
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	runes := []rune(strings.ToLower(s))
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+func title(s string) string {
+	runes := []rune(s)
+	start := true
+	for i, r := range runes {
+		if unicode.IsLetter(r) {
+			if start {
+				runes[i] = unicode.ToUpper(r)
+			} else {
+				runes[i] = unicode.ToLower(r)
+			}
+			start = false
+		} else {
+			start = true
+		}
+	}
+	return string(runes)
+}
+
+func dictItems(value any) (any, error) {
+	dict, ok := value.(map[string]any)
+	if !ok {
+		if _, isUndefined := value.(undefined); isUndefined {
+			return []any{}, nil
+		}
+		return nil, fmt.Errorf("%s is not a mapping", repr(value))
+	}
+	items := make([]any, 0, len(dict))
+	for _, key := range sortedKeys(dict) {
+		items = append(items, []any{key, dict[key]})
+	}
+	return items, nil
+}
+
+// toJSON serializes a value like the tojson filter of Hugging Face, i.e. json.dumps
+// with its default separators and without escaping non-ASCII or HTML characters
+func toJSON(value any, indent int) (string, error) {
+	var buf bytes.Buffer
+	if err := writeJSON(&buf, value, indent, 0); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func writeJSON(buf *bytes.Buffer, value any, indent, depth int) error {
+	newline := func(depth int) {
+		if indent > 0 {
+			buf.WriteString("\n" + strings.Repeat(" ", indent*depth))
+		}
+	}
+	separator := ", "
+	if indent > 0 {
+		separator = ","
+	}
+
+	switch v := value.(type) {
+	case nil, undefined:
+		buf.WriteString("null")
+	case bool, int, float64:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+	case string:
+		encoder := json.NewEncoder(buf)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(v); err != nil {
+			return err
+		}
+		buf.Truncate(buf.Len() - 1)
+	case []any:
+		buf.WriteString("[")
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteString(separator)
+			}
+			newline(depth + 1)
+			if err := writeJSON(buf, item, indent, depth+1); err != nil {
+				return err
+			}
+		}
+		if len(v) > 0 {
+			newline(depth)
+		}
+		buf.WriteString("]")
+	case map[string]any:
+		buf.WriteString("{")
+		for i, key := range sortedKeys(v) {
+			if i > 0 {
+				buf.WriteString(separator)
+			}
+			newline(depth + 1)
+			if err := writeJSON(buf, key, indent, depth+1); err != nil {
+				return err
+			}
+			buf.WriteString(": ")
+			if err := writeJSON(buf, v[key], indent, depth+1); err != nil {
+				return err
+			}
+		}
+		if len(v) > 0 {
+			newline(depth)
+		}
+		buf.WriteString("}")
+	case *namespace:
+		return writeJSON(buf, v.vars, indent, depth)
+	default:
+		return fmt.Errorf("%s is not JSON serializable", repr(value))
+	}
+	return nil
+}
+
+type testFunc func(value any, args []any) (bool, error)
+
+var tests map[string]testFunc
+
+func init() {
+	isType := func(check func(any) bool) testFunc {
+		return func(value any, _ []any) (bool, error) {
+			return check(value), nil
+		}
+	}
+	equalTo := func(value any, args []any) (bool, error) {
+		if len(args) == 0 {
+			return false, fmt.Errorf("equalto expects a value")
+		}
+		return equal(value, args[0]), nil
+	}
+
+	tests = map[string]testFunc{
+		"defined": isType(func(v any) bool {
+			_, ok := v.(undefined)
+			return !ok
+		}),
+		"undefined": isType(func(v any) bool {
+			_, ok := v.(undefined)
+			return ok
+		}),
+		"none": isType(func(v any) bool { return v == nil }),
+		"string": isType(func(v any) bool {
+			_, ok := v.(string)
+			return ok
+		}),
+		"number": isType(func(v any) bool {
+			switch v.(type) {
+			case int, float64:
+				return true
+			}
+			return false
+		}),
+		"integer": isType(func(v any) bool {
+			_, ok := v.(int)
+			return ok
+		}),
+		"float": isType(func(v any) bool {
+			_, ok := v.(float64)
+			return ok
+		}),
+		"boolean": isType(func(v any) bool {
+			_, ok := v.(bool)
+			return ok
+		}),
+		"true":  isType(func(v any) bool { return v == true }),
+		"false": isType(func(v any) bool { return v == false }),
+		"mapping": isType(func(v any) bool {
+			_, ok := v.(map[string]any)
+			return ok
+		}),
+		"sequence": isType(func(v any) bool {
+			switch v.(type) {
+			case []any, string, map[string]any:
+				return true
+			}
+			return false
+		}),
+		"iterable": isType(func(v any) bool {
+			switch v.(type) {
+			case []any, string, map[string]any:
+				return true
+			}
+			return false
+		}),
+		"callable": isType(func(v any) bool {
+			_, ok := v.(callable)
+			return ok
+		}),
+		"truthy": isType(truthy),
+		"odd": isType(func(v any) bool {
+			n, ok := v.(int)
+			return ok && n%2 != 0
+		}),
+		"even": isType(func(v any) bool {
+			n, ok := v.(int)
+			return ok && n%2 == 0
+		}),
+		"divisibleby": func(value any, args []any) (bool, error) {
+			n, ok := value.(int)
+			if len(args) == 0 {
+				return false, fmt.Errorf("divisibleby expects a divisor")
+			}
+			d, okDivisor := args[0].(int)
+			if !ok || !okDivisor || d == 0 {
+				return false, nil
+			}
+			return n%d == 0, nil
+		},
+		"equalto": equalTo,
+		"eq":      equalTo,
+		"==":      equalTo,
+		"sameas": func(value any, args []any) (bool, error) {
+			if len(args) == 0 {
+				return false, fmt.Errorf("sameas expects a value")
+			}
+			switch args[0].(type) {
+			case nil, bool:
+				return value == args[0], nil
+			}
+			return false, nil
+		},
+		"in": func(value any, args []any) (bool, error) {
+			if len(args) == 0 {
+				return false, fmt.Errorf("in expects a container")
+			}
+			return contains(args[0], value)
+		},
+	}
+	tests["ne"] = func(value any, args []any) (bool, error) {
+		result, err := equalTo(value, args)
+		return !result, err
+	}
+}
+
+// boundMethod returns the Python method of a string or a dict, if supported
+func boundMethod(object any, name string) callable {
+	switch v := object.(type) {
+	case string:
+		return stringMethod(v, name)
+	case map[string]any:
+		switch name {
+		case "items":
+			return func(_ []any, _ map[string]any) (any, error) { return dictItems(v) }
+		case "keys":
+			return func(_ []any, _ map[string]any) (any, error) { return iterate(v) }
+		case "values":
+			return func(_ []any, _ map[string]any) (any, error) {
+				values := make([]any, 0, len(v))
+				for _, key := range sortedKeys(v) {
+					values = append(values, v[key])
+				}
+				return values, nil
+			}
+		case "get":
+			return func(args []any, _ map[string]any) (any, error) {
+				if len(args) == 0 {
+					return nil, fmt.Errorf("get() expects a key")
+				}
+				if value, ok := v[toString(args[0])]; ok {
+					return value, nil
+				}
+				if len(args) > 1 {
+					return args[1], nil
+				}
+				return nil, nil
+			}
+		}
+	}
+	return nil
+}
+
+func stringMethod(s, name string) callable {
+	strip := func(trim func(string, string) string, trimSpace func(string) string) callable {
+		return func(args []any, _ map[string]any) (any, error) {
+			if len(args) > 0 && !isNone(args[0]) {
+				return trim(s, toString(args[0])), nil
+			}
+			return trimSpace(s), nil
+		}
+	}
+	affix := func(has func(string, string) bool) callable {
+		return func(args []any, _ map[string]any) (any, error) {
+			if len(args) == 0 {
+				return nil, fmt.Errorf("%s() expects an argument", name)
+			}
+			// Python accepts a tuple of candidates
+			if candidates, ok := args[0].([]any); ok {
+				for _, candidate := range candidates {
+					if has(s, toString(candidate)) {
+						return true, nil
+					}
+				}
+				return false, nil
+			}
+			return has(s, toString(args[0])), nil
+		}
+	}
+	transform := func(f func(string) string) callable {
+		return func(_ []any, _ map[string]any) (any, error) { return f(s), nil }
+	}
+
+	switch name {
+	case "strip":
+		return strip(strings.Trim, strings.TrimSpace)
+	case "lstrip":
+		return strip(strings.TrimLeft, func(s string) string { return strings.TrimLeftFunc(s, unicode.IsSpace) })
+	case "rstrip":
+		return strip(strings.TrimRight, func(s string) string { return strings.TrimRightFunc(s, unicode.IsSpace) })
+	case "startswith":
+		return affix(strings.HasPrefix)
+	case "endswith":
+		return affix(strings.HasSuffix)
+	case "upper":
+		return transform(strings.ToUpper)
+	case "lower":
+		return transform(strings.ToLower)
+	case "title":
+		return transform(title)
+	case "capitalize":
+		return transform(capitalize)
+	case "replace":
+		return func(args []any, _ map[string]any) (any, error) {
+			if len(args) < 2 {
+				return nil, fmt.Errorf("replace() expects 2 arguments")
+			}
+			return strings.ReplaceAll(s, toString(args[0]), toString(args[1])), nil
+		}
+	case "split":
+		return func(args []any, _ map[string]any) (any, error) {
+			var parts []string
+			if len(args) > 0 && !isNone(args[0]) {
+				limit := -1
+				if len(args) > 1 {
+					if n, ok := args[1].(int); ok && n >= 0 {
+						limit = n + 1
+					}
+				}
+				parts = strings.SplitN(s, toString(args[0]), limit)
+			} else {
+				parts = strings.Fields(s)
+			}
+			values := make([]any, 0, len(parts))
+			for _, part := range parts {
+				values = append(values, part)
+			}
+			return values, nil
+		}
+	case "join":
+		return func(args []any, _ map[string]any) (any, error) {
+			if len(args) == 0 {
+				return nil, fmt.Errorf("join() expects an iterable")
+			}
+			return filters["join"](args[0], []any{s}, nil)
+		}
+	}
+	return nil
+}
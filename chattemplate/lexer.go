@@ -0,0 +1,245 @@
+package chattemplate
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type segmentKind int
+
+const (
+	segmentText segmentKind = iota
+	segmentOutput
+	segmentStatement
+)
+
+// segment is a piece of template source: raw text, a {{ ... }} expression or a {% ... %} statement
+type segment struct {
+	kind    segmentKind
+	content string
+	line    int
+}
+
+// split cuts the source into segments, dropping comments and applying the whitespace control
+// of the tags ({%- ... -%}) as well as the trim_blocks and lstrip_blocks options of the
+// Hugging Face environment
+func split(source string) ([]segment, error) {
+	var segments []segment
+	trimNext := false
+	newlineNext := false
+	line := 1
+
+	for pos := 0; pos < len(source); {
+		start := nextTag(source, pos)
+		text := source[pos:]
+		if start >= 0 {
+			text = source[pos:start]
+		}
+
+		if trimNext {
+			text = strings.TrimLeftFunc(text, unicode.IsSpace)
+		} else if newlineNext {
+			text = strings.TrimPrefix(strings.TrimPrefix(text, "\r"), "\n")
+		}
+		trimNext, newlineNext = false, false
+
+		if start < 0 {
+			if text != "" {
+				segments = append(segments, segment{kind: segmentText, content: text, line: line})
+			}
+			break
+		}
+
+		opening := source[start : start+2]
+		closing := map[string]string{"{{": "}}", "{%": "%}", "{#": "#}"}[opening]
+		inner := start + 2
+
+		// whitespace control of the opening tag
+		switch {
+		case inner < len(source) && source[inner] == '-':
+			text = strings.TrimRightFunc(text, unicode.IsSpace)
+			inner++
+		case inner < len(source) && source[inner] == '+':
+			inner++
+		case opening != "{{":
+			text = lstripBlock(text, pos == 0 || source[pos-1] == '\n')
+		}
+
+		if text != "" {
+			segments = append(segments, segment{kind: segmentText, content: text, line: line})
+		}
+		line += strings.Count(source[pos:start], "\n")
+
+		end := closingTag(source, inner, closing)
+		if end < 0 {
+			return nil, fmt.Errorf("line %d: unclosed %s tag", line, opening)
+		}
+
+		content := source[inner:end]
+		if strings.HasSuffix(content, "-") {
+			content = content[:len(content)-1]
+			trimNext = true
+		} else if strings.HasSuffix(content, "+") {
+			content = content[:len(content)-1]
+		} else if opening != "{{" {
+			newlineNext = true
+		}
+
+		switch opening {
+		case "{{":
+			segments = append(segments, segment{kind: segmentOutput, content: strings.TrimSpace(content), line: line})
+		case "{%":
+			segments = append(segments, segment{kind: segmentStatement, content: strings.TrimSpace(content), line: line})
+		}
+
+		line += strings.Count(source[start:end], "\n")
+		pos = end + len(closing)
+	}
+
+	return segments, nil
+}
+
+// nextTag returns the position of the next opening tag, or -1
+func nextTag(source string, pos int) int {
+	for i := pos; i+1 < len(source); i++ {
+		if source[i] == '{' && (source[i+1] == '{' || source[i+1] == '%' || source[i+1] == '#') {
+			return i
+		}
+	}
+	return -1
+}
+
+// closingTag returns the position of the closing tag, skipping the string literals of expressions
+func closingTag(source string, pos int, closing string) int {
+	var quote byte
+	for i := pos; i < len(source); i++ {
+		c := source[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case closing != "#}" && (c == '\'' || c == '"'):
+			quote = c
+		case strings.HasPrefix(source[i:], closing):
+			return i
+		}
+	}
+	return -1
+}
+
+// lstripBlock removes the spaces and tabs preceding a block tag when they are alone on their line
+func lstripBlock(text string, atLineStart bool) string {
+	stripped := strings.TrimRight(text, " \t")
+	if (stripped == "" && atLineStart) || strings.HasSuffix(stripped, "\n") {
+		return stripped
+	}
+	return text
+}
+
+type tokenKind int
+
+const (
+	tokenName tokenKind = iota
+	tokenString
+	tokenInt
+	tokenFloat
+	tokenOperator
+	tokenEOF
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+}
+
+// operators are sorted so that the longest operators are matched first
+var operators = []string{
+	"==", "!=", "<=", ">=", "//", "**",
+	"<", ">", "+", "-", "*", "/", "%", "~", "|", ".", ",", ":", "(", ")", "[", "]", "{", "}", "=",
+}
+
+// tokenize cuts an expression or a statement into tokens
+func tokenize(source string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(source); {
+		c := source[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i
+			for j < len(source) && (source[j] == '_' || unicode.IsLetter(rune(source[j])) || unicode.IsDigit(rune(source[j]))) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenName, value: source[i:j]})
+			i = j
+		case unicode.IsDigit(rune(c)):
+			j := i
+			kind := tokenInt
+			for j < len(source) && (unicode.IsDigit(rune(source[j])) ||
+				(source[j] == '.' && kind == tokenInt && j+1 < len(source) && unicode.IsDigit(rune(source[j+1])))) {
+				if source[j] == '.' {
+					kind = tokenFloat
+				}
+				j++
+			}
+			tokens = append(tokens, token{kind: kind, value: source[i:j]})
+			i = j
+		case c == '\'' || c == '"':
+			value, length, err := unquote(source[i:])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenString, value: value})
+			i += length
+		default:
+			matched := false
+			for _, operator := range operators {
+				if strings.HasPrefix(source[i:], operator) {
+					tokens = append(tokens, token{kind: tokenOperator, value: operator})
+					i += len(operator)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF}), nil
+}
+
+// unquote reads the string literal at the beginning of the source and returns its value and its length
+func unquote(source string) (string, int, error) {
+	quote := source[0]
+	var value strings.Builder
+	for i := 1; i < len(source); i++ {
+		c := source[i]
+		switch {
+		case c == quote:
+			return value.String(), i + 1, nil
+		case c == '\\' && i+1 < len(source):
+			i++
+			switch source[i] {
+			case 'n':
+				value.WriteByte('\n')
+			case 't':
+				value.WriteByte('\t')
+			case 'r':
+				value.WriteByte('\r')
+			default:
+				value.WriteByte(source[i])
+			}
+		default:
+			value.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string literal")
+}
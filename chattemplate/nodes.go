@@ -0,0 +1,583 @@
+package chattemplate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	errBreak    = errors.New("break outside of a loop")
+	errContinue = errors.New("continue outside of a loop")
+)
+
+// scope holds the variables of a block; lookups fall back to the enclosing scopes
+type scope struct {
+	vars   map[string]any
+	parent *scope
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{vars: make(map[string]any), parent: parent}
+}
+
+func (s *scope) lookup(name string) (any, bool) {
+	for current := s; current != nil; current = current.parent {
+		if value, ok := current.vars[name]; ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// root returns the outermost scope, holding the global variables and the macros
+func (s *scope) root() *scope {
+	current := s
+	for current.parent != nil {
+		current = current.parent
+	}
+	return current
+}
+
+type node interface {
+	render(s *scope, out *strings.Builder) error
+}
+
+func renderNodes(nodes []node, s *scope, out *strings.Builder) error {
+	for _, n := range nodes {
+		if err := n.render(s, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type textNode string
+
+func (n textNode) render(_ *scope, out *strings.Builder) error {
+	out.WriteString(string(n))
+	return nil
+}
+
+type outputNode struct {
+	expr expr
+}
+
+func (n *outputNode) render(s *scope, out *strings.Builder) error {
+	value, err := n.expr.eval(s)
+	if err != nil {
+		return err
+	}
+	out.WriteString(toString(value))
+	return nil
+}
+
+type blockNode []node
+
+func (n blockNode) render(s *scope, out *strings.Builder) error {
+	return renderNodes(n, s, out)
+}
+
+type ifBranch struct {
+	condition expr
+	body      []node
+}
+
+type ifNode struct {
+	branches  []ifBranch
+	otherwise []node
+}
+
+func (n *ifNode) render(s *scope, out *strings.Builder) error {
+	for _, branch := range n.branches {
+		condition, err := branch.condition.eval(s)
+		if err != nil {
+			return err
+		}
+		if truthy(condition) {
+			return renderNodes(branch.body, s, out)
+		}
+	}
+	return renderNodes(n.otherwise, s, out)
+}
+
+type forNode struct {
+	targets   []string
+	iterable  expr
+	filter    expr
+	body      []node
+	otherwise []node
+}
+
+func (n *forNode) render(s *scope, out *strings.Builder) error {
+	value, err := n.iterable.eval(s)
+	if err != nil {
+		return err
+	}
+	items, err := iterate(value)
+	if err != nil {
+		return err
+	}
+
+	// the filter is applied before the loop so that loop.length, loop.last, ... ignore skipped items
+	if n.filter != nil {
+		var kept []any
+		for _, item := range items {
+			itemScope := newScope(s)
+			if err := n.assign(itemScope, item); err != nil {
+				return err
+			}
+			keep, err := n.filter.eval(itemScope)
+			if err != nil {
+				return err
+			}
+			if truthy(keep) {
+				kept = append(kept, item)
+			}
+		}
+		items = kept
+	}
+
+	if len(items) == 0 {
+		return renderNodes(n.otherwise, s, out)
+	}
+
+	for i, item := range items {
+		iterationScope := newScope(s)
+		if err := n.assign(iterationScope, item); err != nil {
+			return err
+		}
+		loop := map[string]any{
+			"index":     i + 1,
+			"index0":    i,
+			"revindex":  len(items) - i,
+			"revindex0": len(items) - i - 1,
+			"first":     i == 0,
+			"last":      i == len(items)-1,
+			"length":    len(items),
+			"previtem":  undefined{name: "previtem"},
+			"nextitem":  undefined{name: "nextitem"},
+		}
+		if i > 0 {
+			loop["previtem"] = items[i-1]
+		}
+		if i < len(items)-1 {
+			loop["nextitem"] = items[i+1]
+		}
+		iterationScope.vars["loop"] = loop
+
+		err := renderNodes(n.body, iterationScope, out)
+		if errors.Is(err, errBreak) {
+			break
+		}
+		if err != nil && !errors.Is(err, errContinue) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// assign binds the loop variables to the item, unpacking it when there are several targets
+func (n *forNode) assign(s *scope, item any) error {
+	if len(n.targets) == 1 {
+		s.vars[n.targets[0]] = item
+		return nil
+	}
+
+	values, ok := item.([]any)
+	if !ok || len(values) != len(n.targets) {
+		return fmt.Errorf("cannot unpack %s into %d variables", repr(item), len(n.targets))
+	}
+	for i, target := range n.targets {
+		s.vars[target] = values[i]
+	}
+	return nil
+}
+
+type loopControlNode string
+
+func (n loopControlNode) render(_ *scope, _ *strings.Builder) error {
+	if n == "break" {
+		return errBreak
+	}
+	return errContinue
+}
+
+type setNode struct {
+	name      string
+	attribute string
+	value     expr
+	body      []node
+}
+
+func (n *setNode) render(s *scope, _ *strings.Builder) error {
+	var value any
+	if n.body != nil {
+		var block strings.Builder
+		if err := renderNodes(n.body, s, &block); err != nil {
+			return err
+		}
+		value = block.String()
+	} else {
+		var err error
+		if value, err = n.value.eval(s); err != nil {
+			return err
+		}
+	}
+
+	if n.attribute == "" {
+		s.vars[n.name] = value
+		return nil
+	}
+
+	target, _ := s.lookup(n.name)
+	ns, ok := target.(*namespace)
+	if !ok {
+		return fmt.Errorf("cannot set attribute %q of %s: not a namespace", n.attribute, n.name)
+	}
+	ns.vars[n.attribute] = value
+	return nil
+}
+
+type macroParameter struct {
+	name         string
+	defaultValue expr
+}
+
+type macroNode struct {
+	name       string
+	parameters []macroParameter
+	body       []node
+}
+
+func (n *macroNode) render(s *scope, _ *strings.Builder) error {
+	s.vars[n.name] = callable(func(args []any, kwargs map[string]any) (any, error) {
+		macroScope := newScope(s.root())
+		for i, parameter := range n.parameters {
+			switch value, ok := kwargs[parameter.name]; {
+			case i < len(args):
+				macroScope.vars[parameter.name] = args[i]
+			case ok:
+				macroScope.vars[parameter.name] = value
+			case parameter.defaultValue != nil:
+				defaultValue, err := parameter.defaultValue.eval(s)
+				if err != nil {
+					return nil, err
+				}
+				macroScope.vars[parameter.name] = defaultValue
+			default:
+				macroScope.vars[parameter.name] = undefined{name: parameter.name}
+			}
+		}
+
+		var out strings.Builder
+		if err := renderNodes(n.body, macroScope, &out); err != nil {
+			return nil, err
+		}
+		return out.String(), nil
+	})
+	return nil
+}
+
+type expr interface {
+	eval(s *scope) (any, error)
+}
+
+type literalExpr struct {
+	value any
+}
+
+func (e literalExpr) eval(_ *scope) (any, error) {
+	return e.value, nil
+}
+
+type nameExpr string
+
+func (e nameExpr) eval(s *scope) (any, error) {
+	if value, ok := s.lookup(string(e)); ok {
+		return value, nil
+	}
+	return undefined{name: string(e)}, nil
+}
+
+type listExpr []expr
+
+func (e listExpr) eval(s *scope) (any, error) {
+	list := make([]any, 0, len(e))
+	for _, item := range e {
+		value, err := item.eval(s)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+	}
+	return list, nil
+}
+
+type dictExpr struct {
+	keys   []expr
+	values []expr
+}
+
+func (e *dictExpr) eval(s *scope) (any, error) {
+	dict := make(map[string]any, len(e.keys))
+	for i := range e.keys {
+		key, err := e.keys[i].eval(s)
+		if err != nil {
+			return nil, err
+		}
+		value, err := e.values[i].eval(s)
+		if err != nil {
+			return nil, err
+		}
+		dict[toString(key)] = value
+	}
+	return dict, nil
+}
+
+type conditionalExpr struct {
+	condition expr
+	then      expr
+	otherwise expr
+}
+
+func (e *conditionalExpr) eval(s *scope) (any, error) {
+	condition, err := e.condition.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	if truthy(condition) {
+		return e.then.eval(s)
+	}
+	if e.otherwise == nil {
+		return undefined{}, nil
+	}
+	return e.otherwise.eval(s)
+}
+
+type logicalExpr struct {
+	operator string
+	left     expr
+	right    expr
+}
+
+func (e *logicalExpr) eval(s *scope) (any, error) {
+	left, err := e.left.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	// like Python, the operands are returned rather than booleans
+	if truthy(left) == (e.operator == "or") {
+		return left, nil
+	}
+	return e.right.eval(s)
+}
+
+type notExpr struct {
+	operand expr
+}
+
+func (e *notExpr) eval(s *scope) (any, error) {
+	value, err := e.operand.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	return !truthy(value), nil
+}
+
+type binaryExpr struct {
+	operator string
+	left     expr
+	right    expr
+}
+
+func (e *binaryExpr) eval(s *scope) (any, error) {
+	left, err := e.left.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	right, err := e.right.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	return binaryOperation(e.operator, left, right)
+}
+
+type attributeExpr struct {
+	object expr
+	name   string
+}
+
+func (e *attributeExpr) eval(s *scope) (any, error) {
+	object, err := e.object.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	return attribute(object, e.name), nil
+}
+
+type subscriptExpr struct {
+	object expr
+	key    expr
+}
+
+func (e *subscriptExpr) eval(s *scope) (any, error) {
+	object, err := e.object.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	key, err := e.key.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	return item(object, key)
+}
+
+type sliceExpr struct {
+	object expr
+	start  expr
+	stop   expr
+	step   expr
+}
+
+func (e *sliceExpr) eval(s *scope) (any, error) {
+	object, err := e.object.eval(s)
+	if err != nil {
+		return nil, err
+	}
+
+	var bounds [3]*int
+	for i, bound := range []expr{e.start, e.stop, e.step} {
+		if bound == nil {
+			continue
+		}
+		value, err := bound.eval(s)
+		if err != nil {
+			return nil, err
+		}
+		if isNone(value) {
+			continue
+		}
+		n, ok := value.(int)
+		if !ok {
+			return nil, fmt.Errorf("slice indices must be integers, got %s", repr(value))
+		}
+		bounds[i] = &n
+	}
+
+	return slice(object, bounds[0], bounds[1], bounds[2])
+}
+
+type keywordArgument struct {
+	name  string
+	value expr
+}
+
+type arguments struct {
+	positional []expr
+	keywords   []keywordArgument
+}
+
+func (a arguments) eval(s *scope) ([]any, map[string]any, error) {
+	args := make([]any, 0, len(a.positional))
+	for _, argument := range a.positional {
+		value, err := argument.eval(s)
+		if err != nil {
+			return nil, nil, err
+		}
+		args = append(args, value)
+	}
+
+	kwargs := make(map[string]any, len(a.keywords))
+	for _, keyword := range a.keywords {
+		value, err := keyword.value.eval(s)
+		if err != nil {
+			return nil, nil, err
+		}
+		kwargs[keyword.name] = value
+	}
+
+	return args, kwargs, nil
+}
+
+type callExpr struct {
+	callee    expr
+	arguments arguments
+}
+
+func (e *callExpr) eval(s *scope) (any, error) {
+	callee, err := e.callee.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	function, ok := callee.(callable)
+	if !ok {
+		return nil, fmt.Errorf("%s is not callable", describe(e.callee, callee))
+	}
+	args, kwargs, err := e.arguments.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	return function(args, kwargs)
+}
+
+type filterExpr struct {
+	operand   expr
+	name      string
+	arguments arguments
+}
+
+func (e *filterExpr) eval(s *scope) (any, error) {
+	filter, ok := filters[e.name]
+	if !ok {
+		return nil, fmt.Errorf("unknown filter %q", e.name)
+	}
+	operand, err := e.operand.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	args, kwargs, err := e.arguments.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	return filter(operand, args, kwargs)
+}
+
+type testExpr struct {
+	operand   expr
+	negated   bool
+	name      string
+	arguments arguments
+}
+
+func (e *testExpr) eval(s *scope) (any, error) {
+	test, ok := tests[e.name]
+	if !ok {
+		return nil, fmt.Errorf("unknown test %q", e.name)
+	}
+	operand, err := e.operand.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	args, _, err := e.arguments.eval(s)
+	if err != nil {
+		return nil, err
+	}
+	result, err := test(operand, args)
+	if err != nil {
+		return nil, err
+	}
+	return result != e.negated, nil
+}
+
+// describe names an expression in error messages
+func describe(e expr, value any) string {
+	switch n := e.(type) {
+	case nameExpr:
+		return string(n)
+	case *attributeExpr:
+		return n.name
+	}
+	return repr(value)
+}
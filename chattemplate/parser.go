@@ -0,0 +1,718 @@
+package chattemplate
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// parser builds the tree of a template from its segments
+type parser struct {
+	segments []segment
+	pos      int
+}
+
+// parseBody parses nodes until one of the end statements, which is returned along with its tokens
+func (p *parser) parseBody(ends ...string) ([]node, string, *tokenStream, error) {
+	var nodes []node
+
+	for p.pos < len(p.segments) {
+		seg := p.segments[p.pos]
+		p.pos++
+
+		switch seg.kind {
+		case segmentText:
+			nodes = append(nodes, textNode(seg.content))
+
+		case segmentOutput:
+			stream, err := newTokenStream(seg)
+			if err != nil {
+				return nil, "", nil, err
+			}
+			e, err := stream.parseExpression()
+			if err != nil {
+				return nil, "", nil, err
+			}
+			if err := stream.expectEnd(); err != nil {
+				return nil, "", nil, err
+			}
+			nodes = append(nodes, &outputNode{expr: e})
+
+		case segmentStatement:
+			stream, err := newTokenStream(seg)
+			if err != nil {
+				return nil, "", nil, err
+			}
+			keyword := stream.next()
+			if keyword.kind != tokenName {
+				return nil, "", nil, stream.errorf("expected a statement, got %q", keyword.value)
+			}
+			for _, end := range ends {
+				if keyword.value == end {
+					return nodes, end, stream, nil
+				}
+			}
+
+			n, err := p.parseStatement(keyword.value, stream)
+			if err != nil {
+				return nil, "", nil, err
+			}
+			if n != nil {
+				nodes = append(nodes, n)
+			}
+		}
+	}
+
+	if len(ends) > 0 {
+		return nil, "", nil, fmt.Errorf("unexpected end of template, expected %q", ends)
+	}
+	return nodes, "", nil, nil
+}
+
+func (p *parser) parseStatement(keyword string, stream *tokenStream) (node, error) {
+	switch keyword {
+	case "if":
+		return p.parseIf(stream)
+	case "for":
+		return p.parseFor(stream)
+	case "set":
+		return p.parseSet(stream)
+	case "macro":
+		return p.parseMacro(stream)
+	case "break", "continue":
+		if err := stream.expectEnd(); err != nil {
+			return nil, err
+		}
+		return loopControlNode(keyword), nil
+	case "generation":
+		// the generation block of the assistant masks of Hugging Face only marks its content
+		body, _, _, err := p.parseBody("endgeneration")
+		if err != nil {
+			return nil, err
+		}
+		return blockNode(body), nil
+	default:
+		return nil, stream.errorf("unsupported statement %q", keyword)
+	}
+}
+
+func (p *parser) parseIf(stream *tokenStream) (node, error) {
+	n := &ifNode{}
+
+	for {
+		condition, err := stream.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if err := stream.expectEnd(); err != nil {
+			return nil, err
+		}
+
+		body, end, next, err := p.parseBody("elif", "else", "endif")
+		if err != nil {
+			return nil, err
+		}
+		n.branches = append(n.branches, ifBranch{condition: condition, body: body})
+
+		switch end {
+		case "elif":
+			stream = next
+		case "else":
+			if err := next.expectEnd(); err != nil {
+				return nil, err
+			}
+			if n.otherwise, _, _, err = p.parseBody("endif"); err != nil {
+				return nil, err
+			}
+			return n, nil
+		default:
+			return n, nil
+		}
+	}
+}
+
+func (p *parser) parseFor(stream *tokenStream) (node, error) {
+	n := &forNode{}
+
+	for {
+		name, err := stream.expectName()
+		if err != nil {
+			return nil, err
+		}
+		n.targets = append(n.targets, name)
+		if !stream.skipOperator(",") {
+			break
+		}
+	}
+
+	if !stream.skipName("in") {
+		return nil, stream.errorf("expected 'in' in for loop")
+	}
+
+	var err error
+	// the iterable is parsed without ternaries so that the trailing 'if' is the loop filter
+	if n.iterable, err = stream.parseOr(); err != nil {
+		return nil, err
+	}
+	if stream.skipName("if") {
+		if n.filter, err = stream.parseExpression(); err != nil {
+			return nil, err
+		}
+	}
+	if err := stream.expectEnd(); err != nil {
+		return nil, err
+	}
+
+	body, end, next, err := p.parseBody("else", "endfor")
+	if err != nil {
+		return nil, err
+	}
+	n.body = body
+
+	if end == "else" {
+		if err := next.expectEnd(); err != nil {
+			return nil, err
+		}
+		if n.otherwise, _, _, err = p.parseBody("endfor"); err != nil {
+			return nil, err
+		}
+	}
+
+	return n, nil
+}
+
+func (p *parser) parseSet(stream *tokenStream) (node, error) {
+	n := &setNode{}
+
+	var err error
+	if n.name, err = stream.expectName(); err != nil {
+		return nil, err
+	}
+	if stream.skipOperator(".") {
+		if n.attribute, err = stream.expectName(); err != nil {
+			return nil, err
+		}
+	}
+
+	// block assignment: {% set name %}...{% endset %}
+	if stream.atEnd() {
+		if n.body, _, _, err = p.parseBody("endset"); err != nil {
+			return nil, err
+		}
+		return n, nil
+	}
+
+	if !stream.skipOperator("=") {
+		return nil, stream.errorf("expected '=' in set statement")
+	}
+	if n.value, err = stream.parseExpression(); err != nil {
+		return nil, err
+	}
+	return n, stream.expectEnd()
+}
+
+func (p *parser) parseMacro(stream *tokenStream) (node, error) {
+	n := &macroNode{}
+
+	var err error
+	if n.name, err = stream.expectName(); err != nil {
+		return nil, err
+	}
+	if !stream.skipOperator("(") {
+		return nil, stream.errorf("expected '(' after macro name")
+	}
+	for !stream.skipOperator(")") {
+		name, err := stream.expectName()
+		if err != nil {
+			return nil, err
+		}
+		var defaultValue expr
+		if stream.skipOperator("=") {
+			if defaultValue, err = stream.parseExpression(); err != nil {
+				return nil, err
+			}
+		}
+		n.parameters = append(n.parameters, macroParameter{name: name, defaultValue: defaultValue})
+		if !stream.skipOperator(",") && !stream.peekOperator(")") {
+			return nil, stream.errorf("expected ',' or ')' in macro parameters")
+		}
+	}
+	if err := stream.expectEnd(); err != nil {
+		return nil, err
+	}
+
+	if n.body, _, _, err = p.parseBody("endmacro"); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// tokenStream parses the expressions of a segment
+type tokenStream struct {
+	tokens []token
+	pos    int
+	line   int
+}
+
+func newTokenStream(seg segment) (*tokenStream, error) {
+	tokens, err := tokenize(seg.content)
+	if err != nil {
+		return nil, fmt.Errorf("line %d: %w", seg.line, err)
+	}
+	return &tokenStream{tokens: tokens, line: seg.line}, nil
+}
+
+func (s *tokenStream) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", s.line, fmt.Sprintf(format, args...))
+}
+
+func (s *tokenStream) peek() token {
+	return s.tokens[s.pos]
+}
+
+func (s *tokenStream) next() token {
+	t := s.tokens[s.pos]
+	if t.kind != tokenEOF {
+		s.pos++
+	}
+	return t
+}
+
+func (s *tokenStream) atEnd() bool {
+	return s.peek().kind == tokenEOF
+}
+
+func (s *tokenStream) expectEnd() error {
+	if !s.atEnd() {
+		return s.errorf("unexpected %q", s.peek().value)
+	}
+	return nil
+}
+
+func (s *tokenStream) expectName() (string, error) {
+	t := s.next()
+	if t.kind != tokenName {
+		return "", s.errorf("expected a name, got %q", t.value)
+	}
+	return t.value, nil
+}
+
+func (s *tokenStream) peekOperator(operator string) bool {
+	t := s.peek()
+	return t.kind == tokenOperator && t.value == operator
+}
+
+func (s *tokenStream) skipOperator(operator string) bool {
+	if s.peekOperator(operator) {
+		s.pos++
+		return true
+	}
+	return false
+}
+
+func (s *tokenStream) peekName(name string) bool {
+	t := s.peek()
+	return t.kind == tokenName && t.value == name
+}
+
+func (s *tokenStream) skipName(name string) bool {
+	if s.peekName(name) {
+		s.pos++
+		return true
+	}
+	return false
+}
+
+func (s *tokenStream) expectOperator(operator string) error {
+	if !s.skipOperator(operator) {
+		return s.errorf("expected %q, got %q", operator, s.peek().value)
+	}
+	return nil
+}
+
+// parseExpression parses a full expression, including the `a if b else c` ternary
+func (s *tokenStream) parseExpression() (expr, error) {
+	e, err := s.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	for s.skipName("if") {
+		condition, err := s.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		var otherwise expr
+		if s.skipName("else") {
+			if otherwise, err = s.parseExpression(); err != nil {
+				return nil, err
+			}
+		}
+		e = &conditionalExpr{condition: condition, then: e, otherwise: otherwise}
+	}
+	return e, nil
+}
+
+func (s *tokenStream) parseOr() (expr, error) {
+	left, err := s.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for s.skipName("or") {
+		right, err := s.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpr{operator: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (s *tokenStream) parseAnd() (expr, error) {
+	left, err := s.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for s.skipName("and") {
+		right, err := s.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalExpr{operator: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (s *tokenStream) parseNot() (expr, error) {
+	if s.skipName("not") {
+		operand, err := s.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{operand: operand}, nil
+	}
+	return s.parseComparison()
+}
+
+func (s *tokenStream) parseComparison() (expr, error) {
+	left, err := s.parseConcat()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		var operator string
+		switch t := s.peek(); {
+		case t.kind == tokenOperator && (t.value == "==" || t.value == "!=" || t.value == "<" ||
+			t.value == ">" || t.value == "<=" || t.value == ">="):
+			operator = t.value
+			s.pos++
+		case t.kind == tokenName && t.value == "in":
+			operator = "in"
+			s.pos++
+		case t.kind == tokenName && t.value == "not" && s.tokens[s.pos+1].kind == tokenName && s.tokens[s.pos+1].value == "in":
+			operator = "not in"
+			s.pos += 2
+		default:
+			return left, nil
+		}
+
+		right, err := s.parseConcat()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{operator: operator, left: left, right: right}
+	}
+}
+
+func (s *tokenStream) parseConcat() (expr, error) {
+	left, err := s.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	for s.skipOperator("~") {
+		right, err := s.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{operator: "~", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (s *tokenStream) parseAdditive() (expr, error) {
+	left, err := s.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for s.peekOperator("+") || s.peekOperator("-") {
+		operator := s.next().value
+		right, err := s.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{operator: operator, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (s *tokenStream) parseMultiplicative() (expr, error) {
+	left, err := s.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for s.peekOperator("*") || s.peekOperator("/") || s.peekOperator("//") || s.peekOperator("%") {
+		operator := s.next().value
+		right, err := s.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{operator: operator, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (s *tokenStream) parseUnary() (expr, error) {
+	if s.peekOperator("-") || s.peekOperator("+") {
+		operator := s.next().value
+		operand, err := s.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if operator == "+" {
+			return operand, nil
+		}
+		return &binaryExpr{operator: "-", left: literalExpr{value: 0}, right: operand}, nil
+	}
+	return s.parsePostfix()
+}
+
+// parsePostfix parses a primary expression followed by attributes, subscripts, calls, filters and tests
+func (s *tokenStream) parsePostfix() (expr, error) {
+	e, err := s.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		switch {
+		case s.skipOperator("."):
+			name, err := s.expectName()
+			if err != nil {
+				return nil, err
+			}
+			e = &attributeExpr{object: e, name: name}
+
+		case s.skipOperator("["):
+			if e, err = s.parseSubscript(e); err != nil {
+				return nil, err
+			}
+
+		case s.peekOperator("("):
+			arguments, err := s.parseArguments()
+			if err != nil {
+				return nil, err
+			}
+			e = &callExpr{callee: e, arguments: arguments}
+
+		case s.skipOperator("|"):
+			name, err := s.expectName()
+			if err != nil {
+				return nil, err
+			}
+			filter := &filterExpr{operand: e, name: name}
+			if s.peekOperator("(") {
+				if filter.arguments, err = s.parseArguments(); err != nil {
+					return nil, err
+				}
+			}
+			e = filter
+
+		case s.skipName("is"):
+			test := &testExpr{operand: e}
+			test.negated = s.skipName("not")
+			if test.name, err = s.expectName(); err != nil {
+				return nil, err
+			}
+			if s.peekOperator("(") {
+				if test.arguments, err = s.parseArguments(); err != nil {
+					return nil, err
+				}
+			} else if t := s.peek(); t.kind == tokenString || t.kind == tokenInt || t.kind == tokenFloat ||
+				(t.kind == tokenName && t.value != "and" && t.value != "or" && t.value != "else" && t.value != "if") {
+				// tests accept a single argument without parentheses: `x is divisibleby 3`
+				argument, err := s.parsePrimary()
+				if err != nil {
+					return nil, err
+				}
+				test.arguments = arguments{positional: []expr{argument}}
+			}
+			e = test
+
+		default:
+			return e, nil
+		}
+	}
+}
+
+func (s *tokenStream) parseSubscript(object expr) (expr, error) {
+	var bounds [3]expr
+	part := 0
+	sliced := false
+
+	for !s.skipOperator("]") {
+		if s.skipOperator(":") {
+			part++
+			sliced = true
+			if part > 2 {
+				return nil, s.errorf("invalid slice")
+			}
+			continue
+		}
+		e, err := s.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		bounds[part] = e
+		if !s.peekOperator(":") && !s.peekOperator("]") {
+			return nil, s.errorf("expected ':' or ']', got %q", s.peek().value)
+		}
+	}
+
+	if sliced {
+		return &sliceExpr{object: object, start: bounds[0], stop: bounds[1], step: bounds[2]}, nil
+	}
+	if bounds[0] == nil {
+		return nil, s.errorf("empty subscript")
+	}
+	return &subscriptExpr{object: object, key: bounds[0]}, nil
+}
+
+func (s *tokenStream) parseArguments() (arguments, error) {
+	var args arguments
+	if err := s.expectOperator("("); err != nil {
+		return args, err
+	}
+
+	for !s.skipOperator(")") {
+		if t := s.peek(); t.kind == tokenName && s.tokens[s.pos+1].kind == tokenOperator && s.tokens[s.pos+1].value == "=" {
+			s.pos += 2
+			value, err := s.parseExpression()
+			if err != nil {
+				return args, err
+			}
+			args.keywords = append(args.keywords, keywordArgument{name: t.value, value: value})
+		} else {
+			value, err := s.parseExpression()
+			if err != nil {
+				return args, err
+			}
+			args.positional = append(args.positional, value)
+		}
+		if !s.skipOperator(",") && !s.peekOperator(")") {
+			return args, s.errorf("expected ',' or ')', got %q", s.peek().value)
+		}
+	}
+
+	return args, nil
+}
+
+func (s *tokenStream) parsePrimary() (expr, error) {
+	t := s.next()
+
+	switch t.kind {
+	case tokenString:
+		value := t.value
+		// adjacent string literals are concatenated
+		for s.peek().kind == tokenString {
+			value += s.next().value
+		}
+		return literalExpr{value: value}, nil
+
+	case tokenInt:
+		value, err := strconv.Atoi(t.value)
+		if err != nil {
+			return nil, s.errorf("invalid integer %q", t.value)
+		}
+		return literalExpr{value: value}, nil
+
+	case tokenFloat:
+		value, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			return nil, s.errorf("invalid number %q", t.value)
+		}
+		return literalExpr{value: value}, nil
+
+	case tokenName:
+		switch t.value {
+		case "true", "True":
+			return literalExpr{value: true}, nil
+		case "false", "False":
+			return literalExpr{value: false}, nil
+		case "none", "None":
+			return literalExpr{value: nil}, nil
+		}
+		return nameExpr(t.value), nil
+
+	case tokenOperator:
+		switch t.value {
+		case "(":
+			e, err := s.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			// tuples are handled as lists
+			if s.peekOperator(",") {
+				items := []expr{e}
+				for s.skipOperator(",") && !s.peekOperator(")") {
+					item, err := s.parseExpression()
+					if err != nil {
+						return nil, err
+					}
+					items = append(items, item)
+				}
+				e = listExpr(items)
+			}
+			return e, s.expectOperator(")")
+
+		case "[":
+			var items []expr
+			for !s.skipOperator("]") {
+				item, err := s.parseExpression()
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, item)
+				if !s.skipOperator(",") && !s.peekOperator("]") {
+					return nil, s.errorf("expected ',' or ']', got %q", s.peek().value)
+				}
+			}
+			return listExpr(items), nil
+
+		case "{":
+			d := &dictExpr{}
+			for !s.skipOperator("}") {
+				key, err := s.parseExpression()
+				if err != nil {
+					return nil, err
+				}
+				if err := s.expectOperator(":"); err != nil {
+					return nil, err
+				}
+				value, err := s.parseExpression()
+				if err != nil {
+					return nil, err
+				}
+				d.keys = append(d.keys, key)
+				d.values = append(d.values, value)
+				if !s.skipOperator(",") && !s.peekOperator("}") {
+					return nil, s.errorf("expected ',' or '}', got %q", s.peek().value)
+				}
+			}
+			return d, nil
+		}
+	}
+
+	if t.kind == tokenEOF {
+		return nil, s.errorf("unexpected end of expression")
+	}
+	return nil, s.errorf("unexpected %q", t.value)
+}
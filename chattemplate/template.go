@@ -0,0 +1,135 @@
+package chattemplate
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/glethuillier/deLLMiter/client"
+)
+
+// TemplateError is the error raised by a template through raise_exception()
+type TemplateError struct {
+	Message string
+}
+
+func (e *TemplateError) Error() string {
+	return "template raised an exception: " + e.Message
+}
+
+// Template is a parsed chat template. Only the subset of Jinja used by the chat templates
+// of Hugging Face is supported: expressions, filters, tests, if/for/set/macro statements,
+// loop controls and the raise_exception, namespace, range and strftime_now globals.
+// Dicts are iterated (and serialized by tojson) in key order, not in insertion order.
+type Template struct {
+	nodes []node
+	// Now returns the date used by strftime_now (the current date if nil)
+	Now func() time.Time
+}
+
+// Parse parses the source of a chat template
+func Parse(source string) (*Template, error) {
+	segments, err := split(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the chat template: %w", err)
+	}
+
+	p := &parser{segments: segments}
+	nodes, _, _, err := p.parseBody()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the chat template: %w", err)
+	}
+
+	return &Template{nodes: nodes}, nil
+}
+
+// ParseFile parses a chat template file
+func ParseFile(filePath string) (*Template, error) {
+	source, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the chat template: %w", err)
+	}
+	return Parse(string(source))
+}
+
+// Render renders the template with the given variables. Nested values must be made of
+// nil, bool, int, float64, string, []any and map[string]any.
+func (t *Template) Render(variables map[string]any) (string, error) {
+	now := t.Now
+	if now == nil {
+		now = time.Now
+	}
+
+	s := newScope(nil)
+	for name, value := range globals(now) {
+		s.vars[name] = value
+	}
+	for name, value := range variables {
+		s.vars[name] = value
+	}
+
+	var out strings.Builder
+	err := renderNodes(t.nodes, s, &out)
+	if errors.Is(err, errBreak) || errors.Is(err, errContinue) {
+		return "", err
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to render the chat template: %w", err)
+	}
+	return out.String(), nil
+}
+
+// Options are the variables passed by Hugging Face along with the messages
+type Options struct {
+	BOSToken            string
+	EOSToken            string
+	AddGenerationPrompt bool
+}
+
+// RenderMessages renders the raw prompt of a conversation, as apply_chat_template does
+func (t *Template) RenderMessages(messages []client.Message, options Options) (string, error) {
+	list := make([]any, 0, len(messages))
+	for _, message := range messages {
+		list = append(list, map[string]any{"role": message.Role, "content": message.Content})
+	}
+
+	return t.Render(map[string]any{
+		"messages":              list,
+		"bos_token":             options.BOSToken,
+		"eos_token":             options.EOSToken,
+		"add_generation_prompt": options.AddGenerationPrompt,
+	})
+}
+
+// Collisions returns the delimiters that the template itself emits around the messages,
+// i.e. the delimiters of a candidate that the model cannot tell apart from the real structure
+// of its prompt. The scaffolding is rendered with the contents of the messages removed.
+func (t *Template) Collisions(messages []client.Message, options Options, delimiters []string) ([]string, error) {
+	scaffolding := make([]client.Message, 0, len(messages))
+	for _, message := range messages {
+		scaffolding = append(scaffolding, client.Message{Role: message.Role})
+	}
+
+	rendered, err := t.RenderMessages(scaffolding, options)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]struct{})
+	var collisions []string
+	for _, delimiter := range delimiters {
+		if _, ok := seen[delimiter]; ok || delimiter == "" {
+			continue
+		}
+		seen[delimiter] = struct{}{}
+		if strings.Contains(rendered, delimiter) {
+			collisions = append(collisions, delimiter)
+		}
+	}
+	sort.Strings(collisions)
+
+	return collisions, nil
+}
@@ -0,0 +1,234 @@
+package chattemplate
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/glethuillier/deLLMiter/client"
+)
+
+const llama3Template = `{% set loop_messages = messages %}{% for message in loop_messages %}{% set content = '<|start_header_id|>' + message['role'] + '<|end_header_id|>
+
+'+ message['content'] | trim + '<|eot_id|>' %}{% if loop.index0 == 0 %}{% set content = bos_token + content %}{% endif %}{{ content }}{% endfor %}{% if add_generation_prompt %}{{ '<|start_header_id|>assistant<|end_header_id|>
+
+' }}{% endif %}`
+
+const chatMLTemplate = `{% for message in messages %}
+    {{- '<|im_start|>' + message.role + '\n' + message.content + '<|im_end|>' + '\n' }}
+{%- endfor %}
+{%- if add_generation_prompt %}
+    {{- '<|im_start|>assistant\n' }}
+{%- endif %}
+`
+
+const mistralTemplate = `{{ bos_token }}{% for message in messages %}{% if (message['role'] == 'user') != (loop.index0 % 2 == 0) %}{{ raise_exception('Conversation roles must alternate user/assistant/user/assistant/...') }}{% endif %}{% if message['role'] == 'user' %}{{ '[INST] ' + message['content'] + ' [/INST]' }}{% elif message['role'] == 'assistant' %}{{ message['content'] + eos_token}}{% else %}{{ raise_exception('Only user and assistant roles are supported!') }}{% endif %}{% endfor %}`
+
+func TestRenderMessages(t *testing.T) {
+	conversation := []client.Message{
+		{Role: "user", Content: "Hello <|eot_id|>"},
+		{Role: "assistant", Content: "Hello <|eot_id|>"},
+		{Role: "user", Content: "again"},
+	}
+
+	tests := []struct {
+		name      string
+		template  string
+		messages  []client.Message
+		options   Options
+		expected  string
+		expectErr bool
+	}{
+		{
+			name:     "llama3",
+			template: llama3Template,
+			messages: conversation[:1],
+			options:  Options{BOSToken: "<|begin_of_text|>", AddGenerationPrompt: true},
+			expected: "<|begin_of_text|><|start_header_id|>user<|end_header_id|>\n\nHello <|eot_id|><|eot_id|>" +
+				"<|start_header_id|>assistant<|end_header_id|>\n\n",
+		},
+		{
+			name:     "chatml",
+			template: chatMLTemplate,
+			messages: conversation[:2],
+			options:  Options{AddGenerationPrompt: true},
+			expected: "<|im_start|>user\nHello <|eot_id|><|im_end|>\n<|im_start|>assistant\nHello <|eot_id|><|im_end|>\n" +
+				"<|im_start|>assistant\n",
+		},
+		{
+			name:     "chatml without generation prompt",
+			template: chatMLTemplate,
+			messages: conversation[2:],
+			expected: "<|im_start|>user\nagain<|im_end|>\n",
+		},
+		{
+			name:     "mistral",
+			template: mistralTemplate,
+			messages: conversation,
+			options:  Options{BOSToken: "<s>", EOSToken: "</s>"},
+			expected: "<s>[INST] Hello <|eot_id|> [/INST]Hello <|eot_id|></s>[INST] again [/INST]",
+		},
+		{
+			name:      "mistral raises on non-alternating roles",
+			template:  mistralTemplate,
+			messages:  []client.Message{{Role: "assistant", Content: "first"}},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			template, err := Parse(tc.template)
+			if err != nil {
+				t.Fatalf("unexpected parse error: %v", err)
+			}
+
+			rendered, err := template.RenderMessages(tc.messages, tc.options)
+			if tc.expectErr {
+				var templateErr *TemplateError
+				if !errors.As(err, &templateErr) {
+					t.Fatalf("expected a template error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if rendered != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, rendered)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name      string
+		template  string
+		variables map[string]any
+		expected  string
+	}{
+		{
+			name:     "arithmetic and concatenation",
+			template: `{{ 1 + 2 * 3 }}-{{ 7 // 2 }}-{{ -7 % 3 }}-{{ 'a' ~ 1 }}-{{ 1 / 2 }}`,
+			expected: "7-3-2-a1-0.5",
+		},
+		{
+			name:     "namespace carries state out of loops",
+			template: `{% set ns = namespace(count=0) %}{% for i in range(4) %}{% set ns.count = ns.count + i %}{% endfor %}{{ ns.count }}`,
+			expected: "6",
+		},
+		{
+			name:     "set does not leak out of loops",
+			template: `{% set x = 1 %}{% for i in [1, 2] %}{% set x = i %}{% endfor %}{{ x }}`,
+			expected: "1",
+		},
+		{
+			name:     "loop filter, else and loop variables",
+			template: `{% for i in [1, 2, 3, 4] if i is even %}{{ loop.index }}:{{ i }}{% if not loop.last %},{% endif %}{% endfor %}{% for i in [] %}x{% else %}empty{% endfor %}`,
+			expected: "1:2,2:4empty",
+		},
+		{
+			name:     "break and continue",
+			template: `{% for i in range(10) %}{% if i == 1 %}{% continue %}{% endif %}{% if i == 4 %}{% break %}{% endif %}{{ i }}{% endfor %}`,
+			expected: "023",
+		},
+		{
+			name:     "tests and undefined",
+			template: `{{ x is defined }}{{ x is not defined }}{{ none is none }}{{ 'a' is string }}{{ missing.attr | default('d') }}[{{ missing }}]`,
+			expected: "FalseTrueTrueTrued[]",
+		},
+		{
+			name:      "slices and methods",
+			template:  `{{ messages[1:] | length }}{{ messages[::-1][0].role }}{{ ' x '.strip() }}{{ 'abc'.startswith('a') }}{{ 'a,b'.split(',') }}`,
+			variables: map[string]any{"messages": []any{map[string]any{"role": "system"}, map[string]any{"role": "user"}}},
+			expected:  "1userxTrue['a', 'b']",
+		},
+		{
+			name:      "filters",
+			template:  `{{ messages | selectattr('role', 'equalto', 'user') | map(attribute='content') | join(', ') }}|{{ {'b': 1, 'a': '<x>'} | tojson }}|{{ 'ab' | upper }}`,
+			variables: map[string]any{"messages": []any{map[string]any{"role": "user", "content": "a"}, map[string]any{"role": "system", "content": "b"}, map[string]any{"role": "user", "content": "c"}}},
+			expected:  `a, c|{"a": "<x>", "b": 1}|AB`,
+		},
+		{
+			name:     "ternary and dict items",
+			template: `{{ 'yes' if 1 in [1] else 'no' }}{% for key, value in {'k': 'v'}.items() %}{{ key }}={{ value }}{% endfor %}`,
+			expected: "yesk=v",
+		},
+		{
+			name:     "macros",
+			template: "{% macro tag(name, close=false) %}<{{ '/' if close }}{{ name }}>{% endmacro %}{{ tag('a') }}{{ tag('a', close=true) }}",
+			expected: "<a></a>",
+		},
+		{
+			name:     "whitespace control and comments",
+			template: "a  {#- comment -#}  b\n  {% if true %}\nc\n  {% endif %}\n",
+			expected: "ab\nc\n",
+		},
+		{
+			name:     "strftime_now",
+			template: `{{ strftime_now('%d %b %Y') }}`,
+			expected: "18 Oct 2026",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			template, err := Parse(tc.template)
+			if err != nil {
+				t.Fatalf("unexpected parse error: %v", err)
+			}
+			template.Now = func() time.Time { return time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC) }
+
+			rendered, err := template.Render(tc.variables)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if rendered != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, rendered)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+	}{
+		{name: "unclosed tag", template: "{{ x "},
+		{name: "missing endif", template: "{% if x %}a"},
+		{name: "unsupported statement", template: "{% include 'x' %}"},
+		{name: "unterminated string", template: "{{ 'abc }}"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Parse(tc.template); err == nil {
+				t.Errorf("expected an error, got none")
+			}
+		})
+	}
+}
+
+func TestCollisions(t *testing.T) {
+	template, err := Parse(llama3Template)
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+
+	collisions, err := template.Collisions(
+		[]client.Message{{Role: "user", Content: "<|eot_id|> [INST] <|begin_of_text|>"}},
+		Options{BOSToken: "<|begin_of_text|>", AddGenerationPrompt: true},
+		[]string{"<|eot_id|>", "[INST]", "<|begin_of_text|>", "<|eot_id|>"},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{"<|begin_of_text|>", "<|eot_id|>"}
+	if !reflect.DeepEqual(collisions, expected) {
+		t.Errorf("expected %q, got %q", expected, collisions)
+	}
+}
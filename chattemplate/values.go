@@ -0,0 +1,451 @@
+package chattemplate
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Values are represented by nil (none), bool, int, float64, string, []any, map[string]any,
+// *namespace, callable and undefined
+
+// undefined is the value of missing variables and attributes; it renders as an empty string
+type undefined struct {
+	name string
+}
+
+// namespace is the mutable object created by namespace(), the only way to carry state out of a loop
+type namespace struct {
+	vars map[string]any
+}
+
+// callable is a global function, a macro or a bound method
+type callable func(args []any, kwargs map[string]any) (any, error)
+
+func isNone(value any) bool {
+	if value == nil {
+		return true
+	}
+	_, ok := value.(undefined)
+	return ok
+}
+
+func truthy(value any) bool {
+	switch v := value.(type) {
+	case nil, undefined:
+		return false
+	case bool:
+		return v
+	case int:
+		return v != 0
+	case float64:
+		return v != 0
+	case string:
+		return v != ""
+	case []any:
+		return len(v) > 0
+	case map[string]any:
+		return len(v) > 0
+	default:
+		return true
+	}
+}
+
+// toString converts a value as Python's str() does
+func toString(value any) string {
+	switch v := value.(type) {
+	case undefined:
+		return ""
+	case string:
+		return v
+	default:
+		return repr(value)
+	}
+}
+
+// repr converts a value as Python's repr() does
+func repr(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "None"
+	case undefined:
+		return ""
+	case bool:
+		if v {
+			return "True"
+		}
+		return "False"
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1e16 {
+			return strconv.FormatFloat(v, 'f', 1, 64)
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`, "\n", `\n`, "\t", `\t`, "\r", `\r`).Replace(v) + "'"
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			items = append(items, repr(item))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case map[string]any:
+		items := make([]string, 0, len(v))
+		for _, key := range sortedKeys(v) {
+			items = append(items, repr(key)+": "+repr(v[key]))
+		}
+		return "{" + strings.Join(items, ", ") + "}"
+	case *namespace:
+		return "<Namespace " + repr(v.vars) + ">"
+	case callable:
+		return "<function>"
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// sortedKeys returns the keys of a dict in a stable order (dicts of Go have no insertion order)
+func sortedKeys(dict map[string]any) []string {
+	keys := make([]string, 0, len(dict))
+	for key := range dict {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// iterate returns the items of a value in a for loop: list items, dict keys or string characters
+func iterate(value any) ([]any, error) {
+	switch v := value.(type) {
+	case undefined:
+		return nil, nil
+	case []any:
+		return v, nil
+	case map[string]any:
+		keys := sortedKeys(v)
+		items := make([]any, 0, len(keys))
+		for _, key := range keys {
+			items = append(items, key)
+		}
+		return items, nil
+	case string:
+		items := make([]any, 0, len(v))
+		for _, r := range v {
+			items = append(items, string(r))
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("%s is not iterable", repr(value))
+	}
+}
+
+func length(value any) (int, error) {
+	switch v := value.(type) {
+	case string:
+		return len([]rune(v)), nil
+	case []any:
+		return len(v), nil
+	case map[string]any:
+		return len(v), nil
+	case undefined:
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("%s has no length", repr(value))
+	}
+}
+
+// attribute returns the attribute of an object: a dict key, a namespace variable or a method
+func attribute(object any, name string) any {
+	switch v := object.(type) {
+	case map[string]any:
+		if value, ok := v[name]; ok {
+			return value
+		}
+	case *namespace:
+		if value, ok := v.vars[name]; ok {
+			return value
+		}
+		return undefined{name: name}
+	}
+
+	if method := boundMethod(object, name); method != nil {
+		return method
+	}
+	return undefined{name: name}
+}
+
+// item returns object[key]
+func item(object any, key any) (any, error) {
+	switch v := object.(type) {
+	case map[string]any:
+		if value, ok := v[toString(key)]; ok {
+			return value, nil
+		}
+		return undefined{name: toString(key)}, nil
+	case []any, string:
+		index, ok := key.(int)
+		if !ok {
+			if name, ok := key.(string); ok {
+				return attribute(object, name), nil
+			}
+			return nil, fmt.Errorf("indices must be integers, got %s", repr(key))
+		}
+		var items []any
+		if list, ok := v.([]any); ok {
+			items = list
+		} else {
+			items, _ = iterate(v)
+		}
+		if index < 0 {
+			index += len(items)
+		}
+		if index < 0 || index >= len(items) {
+			return undefined{}, nil
+		}
+		return items[index], nil
+	case undefined:
+		return undefined{}, nil
+	case *namespace:
+		return attribute(v, toString(key)), nil
+	default:
+		return nil, fmt.Errorf("%s is not subscriptable", repr(object))
+	}
+}
+
+// slice returns object[start:stop:step] with the semantics of Python
+func slice(object any, start, stop, step *int) (any, error) {
+	var items []any
+	switch v := object.(type) {
+	case []any:
+		items = v
+	case string:
+		items, _ = iterate(v)
+	case undefined:
+		return undefined{}, nil
+	default:
+		return nil, fmt.Errorf("%s cannot be sliced", repr(object))
+	}
+
+	n := len(items)
+	increment := 1
+	if step != nil {
+		increment = *step
+	}
+	if increment == 0 {
+		return nil, fmt.Errorf("slice step cannot be zero")
+	}
+
+	bound := func(value *int, defaultValue int) int {
+		if value == nil {
+			return defaultValue
+		}
+		index := *value
+		if index < 0 {
+			index += n
+		}
+		if increment > 0 {
+			return max(0, min(index, n))
+		}
+		return max(-1, min(index, n-1))
+	}
+
+	var result []any
+	if increment > 0 {
+		for i := bound(start, 0); i < bound(stop, n); i += increment {
+			result = append(result, items[i])
+		}
+	} else {
+		for i := bound(start, n-1); i > bound(stop, -1); i += increment {
+			result = append(result, items[i])
+		}
+	}
+
+	if _, ok := object.(string); ok {
+		var s strings.Builder
+		for _, r := range result {
+			s.WriteString(r.(string))
+		}
+		return s.String(), nil
+	}
+	if result == nil {
+		result = []any{}
+	}
+	return result, nil
+}
+
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
+
+func equal(left, right any) bool {
+	if isNone(left) || isNone(right) {
+		return isNone(left) && isNone(right) && reflect.TypeOf(left) == reflect.TypeOf(right)
+	}
+	if l, ok := toFloat(left); ok {
+		if r, ok := toFloat(right); ok {
+			return l == r
+		}
+	}
+	return reflect.DeepEqual(left, right)
+}
+
+func compare(left, right any) (int, error) {
+	if l, ok := toFloat(left); ok {
+		if r, ok := toFloat(right); ok {
+			switch {
+			case l < r:
+				return -1, nil
+			case l > r:
+				return 1, nil
+			default:
+				return 0, nil
+			}
+		}
+	}
+	if l, ok := left.(string); ok {
+		if r, ok := right.(string); ok {
+			return strings.Compare(l, r), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare %s and %s", repr(left), repr(right))
+}
+
+func contains(container, element any) (bool, error) {
+	switch v := container.(type) {
+	case string:
+		s, ok := element.(string)
+		if !ok {
+			return false, fmt.Errorf("'in <string>' requires a string, got %s", repr(element))
+		}
+		return strings.Contains(v, s), nil
+	case []any:
+		for _, item := range v {
+			if equal(item, element) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case map[string]any:
+		key, ok := element.(string)
+		if !ok {
+			return false, nil
+		}
+		_, found := v[key]
+		return found, nil
+	case undefined:
+		return false, nil
+	default:
+		return false, fmt.Errorf("%s is not a container", repr(container))
+	}
+}
+
+func binaryOperation(operator string, left, right any) (any, error) {
+	switch operator {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "<", ">", "<=", ">=":
+		c, err := compare(left, right)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]bool{"<": c < 0, ">": c > 0, "<=": c <= 0, ">=": c >= 0}[operator], nil
+	case "in", "not in":
+		found, err := contains(right, left)
+		if err != nil {
+			return nil, err
+		}
+		return found == (operator == "in"), nil
+	case "~":
+		return toString(left) + toString(right), nil
+	}
+
+	// arithmetic
+	if l, ok := left.(string); ok && operator == "+" {
+		if r, ok := right.(string); ok {
+			return l + r, nil
+		}
+	}
+	if l, ok := left.([]any); ok && operator == "+" {
+		if r, ok := right.([]any); ok {
+			return append(append([]any{}, l...), r...), nil
+		}
+	}
+	if l, ok := left.(string); ok && operator == "*" {
+		if r, ok := right.(int); ok {
+			return strings.Repeat(l, max(r, 0)), nil
+		}
+	}
+
+	li, leftInt := left.(int)
+	ri, rightInt := right.(int)
+	if leftInt && rightInt {
+		switch operator {
+		case "+":
+			return li + ri, nil
+		case "-":
+			return li - ri, nil
+		case "*":
+			return li * ri, nil
+		case "//", "%":
+			if ri == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			quotient := li / ri
+			if (li%ri != 0) && ((li < 0) != (ri < 0)) {
+				quotient--
+			}
+			if operator == "//" {
+				return quotient, nil
+			}
+			return li - quotient*ri, nil
+		}
+	}
+
+	lf, leftNumber := toFloat(left)
+	rf, rightNumber := toFloat(right)
+	if !leftNumber || !rightNumber {
+		return nil, fmt.Errorf("unsupported operand types for %s: %s and %s", operator, repr(left), repr(right))
+	}
+	switch operator {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	case "/":
+		if rf == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return lf / rf, nil
+	case "//":
+		if rf == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return math.Floor(lf / rf), nil
+	case "%":
+		if rf == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return lf - math.Floor(lf/rf)*rf, nil
+	}
+	return nil, fmt.Errorf("unknown operator %q", operator)
+}
@@ -77,6 +77,8 @@ type RuntimeInfo struct {
 type Client struct {
 	queryURL   string
 	httpClient *http.Client
+	strategies *StrategyRegistry
+	sampling   Sampling
 }
 
 func NewClient(baseURL string, requestedModel string) (*Client, error) {
@@ -120,9 +122,54 @@ func NewClient(baseURL string, requestedModel string) (*Client, error) {
 	return nil, fmt.Errorf("model %s not supported/loaded by the API server", requestedModel)
 }
 
+// UseStrategies sets the registry from which the prompt strategy of the queried model is selected
+func (c *Client) UseStrategies(registry *StrategyRegistry) {
+	c.strategies = registry
+}
+
+// UseSampling sets the sampling parameters overriding those of the prompt strategies
+func (c *Client) UseSampling(sampling Sampling) {
+	c.sampling = sampling
+}
+
+// WithSampling returns a copy of the client using the given sampling parameters
+func (c *Client) WithSampling(sampling Sampling) *Client {
+	clone := *c
+	clone.sampling = sampling
+	return &clone
+}
+
+// EffectiveSampling returns the sampling parameters sent to the model with the given strategy
+func (c *Client) EffectiveSampling(strategy PromptStrategy, modelName string) Sampling {
+	prompt := strategy.Build(modelName, nil, "")
+	c.sampling.apply(&prompt)
+	return prompt.Sampling()
+}
+
 // Query sends a request with specified model and message content, returning the response text or an error if encountered.
 func (c *Client) Query(modelName string, messageContent string) (string, error) {
-	requestJSON, err := json.Marshal(getPrompt(modelName, messageContent))
+	return c.QueryConversation(modelName, nil, messageContent)
+}
+
+// QueryConversation sends the history of a conversation followed by the message content,
+// returning the response text or an error if encountered.
+func (c *Client) QueryConversation(modelName string, history []Message, messageContent string) (string, error) {
+	registry := c.strategies
+	if registry == nil {
+		registry = DefaultStrategyRegistry()
+	}
+	_, strategy := registry.Lookup(modelName)
+
+	return c.QueryWithStrategy(strategy, modelName, history, messageContent)
+}
+
+// QueryWithStrategy sends the history of a conversation followed by the message content,
+// laid out by the given strategy rather than the one registered for the model
+func (c *Client) QueryWithStrategy(strategy PromptStrategy, modelName string, history []Message, messageContent string) (string, error) {
+	prompt := strategy.Build(modelName, history, messageContent)
+	c.sampling.apply(&prompt)
+
+	requestJSON, err := json.Marshal(prompt)
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
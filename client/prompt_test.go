@@ -4,87 +4,7 @@ import (
 	"testing"
 )
 
-func TestGetSystemMessage(t *testing.T) {
-	tests := []struct {
-		name string
-		role string
-		want Message
-	}{
-		{
-			name: "basic case",
-			role: "assistant",
-			want: Message{
-				Role:    "assistant",
-				Content: systemConstraints,
-			},
-		},
-		{
-			name: "empty role",
-			role: "",
-			want: Message{
-				Role:    "",
-				Content: systemConstraints,
-			},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := getSystemMessage(tt.role)
-			if got != tt.want {
-				t.Errorf("getSystemMessage() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
-func TestGetUserMessage(t *testing.T) {
-	tests := []struct {
-		name    string
-		role    string
-		content string
-		want    Message
-	}{
-		{
-			name:    "basic case",
-			role:    "user",
-			content: "Hello",
-			want: Message{
-				Role:    "user",
-				Content: userConstraints + ": Hello",
-			},
-		},
-		{
-			name:    "empty content",
-			role:    "user",
-			content: "",
-			want: Message{
-				Role:    "user",
-				Content: userConstraints + ": ",
-			},
-		},
-		{
-			name:    "empty role",
-			role:    "",
-			content: "Some Content",
-			want: Message{
-				Role:    "",
-				Content: userConstraints + ": Some Content",
-			},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := getUserMessage(tt.role, tt.content)
-			if got != tt.want {
-				t.Errorf("getUserMessage() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
-
-func TestGetPrompt(t *testing.T) {
+func TestDefaultStrategyBuild(t *testing.T) {
 	tests := []struct {
 		name      string
 		modelName string
@@ -96,8 +16,11 @@ func TestGetPrompt(t *testing.T) {
 			modelName: "Mistral 7B",
 			content:   "Hello",
 			want: Prompt{
-				Model:       "mistral 7b",
-				Messages:    []Message{getSystemMessage("assistant"), getUserMessage("user", "Hello")},
+				Model: "mistral 7b",
+				Messages: []Message{
+					{Role: "assistant", Content: systemConstraints},
+					{Role: "user", Content: userConstraints + ": Hello"},
+				},
 				Temperature: 0.8,
 				Stream:      false,
 			},
@@ -108,7 +31,7 @@ func TestGetPrompt(t *testing.T) {
 			content:   "How are you?",
 			want: Prompt{
 				Model:       "hermes-3",
-				Messages:    []Message{getUserMessage("user", "How are you?")},
+				Messages:    []Message{{Role: "user", Content: userConstraints + ": How are you?"}},
 				Temperature: 0.8,
 				Stream:      false,
 			},
@@ -118,8 +41,11 @@ func TestGetPrompt(t *testing.T) {
 			modelName: "gpt-4",
 			content:   "Tell me something",
 			want: Prompt{
-				Model:       "gpt-4",
-				Messages:    []Message{getSystemMessage("system"), getUserMessage("user", "Tell me something")},
+				Model: "gpt-4",
+				Messages: []Message{
+					{Role: "system", Content: systemConstraints},
+					{Role: "user", Content: userConstraints + ": Tell me something"},
+				},
 				Temperature: 0.8,
 				Stream:      false,
 			},
@@ -129,8 +55,11 @@ func TestGetPrompt(t *testing.T) {
 			modelName: "  GPT-4 ",
 			content:   "Some text",
 			want: Prompt{
-				Model:       "gpt-4",
-				Messages:    []Message{getSystemMessage("system"), getUserMessage("user", "Some text")},
+				Model: "gpt-4",
+				Messages: []Message{
+					{Role: "system", Content: systemConstraints},
+					{Role: "user", Content: userConstraints + ": Some text"},
+				},
 				Temperature: 0.8,
 				Stream:      false,
 			},
@@ -140,8 +69,11 @@ func TestGetPrompt(t *testing.T) {
 			modelName: "",
 			content:   "Empty model",
 			want: Prompt{
-				Model:       "",
-				Messages:    []Message{getSystemMessage("system"), getUserMessage("user", "Empty model")},
+				Model: "",
+				Messages: []Message{
+					{Role: "system", Content: systemConstraints},
+					{Role: "user", Content: userConstraints + ": Empty model"},
+				},
 				Temperature: 0.8,
 				Stream:      false,
 			},
@@ -152,7 +84,7 @@ func TestGetPrompt(t *testing.T) {
 			content:   "",
 			want: Prompt{
 				Model:       "hermes-3",
-				Messages:    []Message{getUserMessage("user", "")},
+				Messages:    []Message{{Role: "user", Content: userConstraints + ": "}},
 				Temperature: 0.8,
 				Stream:      false,
 			},
@@ -162,8 +94,11 @@ func TestGetPrompt(t *testing.T) {
 			modelName: "MiStRaL",
 			content:   "Check sensitivity",
 			want: Prompt{
-				Model:       "mistral",
-				Messages:    []Message{getSystemMessage("assistant"), getUserMessage("user", "Check sensitivity")},
+				Model: "mistral",
+				Messages: []Message{
+					{Role: "assistant", Content: systemConstraints},
+					{Role: "user", Content: userConstraints + ": Check sensitivity"},
+				},
 				Temperature: 0.8,
 				Stream:      false,
 			},
@@ -172,23 +107,72 @@ func TestGetPrompt(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := getPrompt(tt.modelName, tt.content)
+			_, strategy := DefaultStrategyRegistry().Lookup(tt.modelName)
+			got := strategy.Build(tt.modelName, nil, tt.content)
 
 			if got.Model != tt.want.Model {
-				t.Errorf("getPrompt().Model = %v, want %v", got.Model, tt.want.Model)
+				t.Errorf("Build().Model = %v, want %v", got.Model, tt.want.Model)
 			}
 			if got.Temperature != tt.want.Temperature {
-				t.Errorf("getPrompt().Temperature = %v, want %v", got.Temperature, tt.want.Temperature)
+				t.Errorf("Build().Temperature = %v, want %v", got.Temperature, tt.want.Temperature)
 			}
 			if got.Stream != tt.want.Stream {
-				t.Errorf("getPrompt().Stream = %v, want %v", got.Stream, tt.want.Stream)
+				t.Errorf("Build().Stream = %v, want %v", got.Stream, tt.want.Stream)
 			}
 			if len(got.Messages) != len(tt.want.Messages) {
-				t.Fatalf("getPrompt().Messages length = %v, want %v", len(got.Messages), len(tt.want.Messages))
+				t.Fatalf("Build().Messages length = %v, want %v", len(got.Messages), len(tt.want.Messages))
 			}
 			for i, msg := range got.Messages {
 				if msg != tt.want.Messages[i] {
-					t.Errorf("getPrompt().Messages[%d] = %v, want %v", i, msg, tt.want.Messages[i])
+					t.Errorf("Build().Messages[%d] = %v, want %v", i, msg, tt.want.Messages[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultStrategyBuildConversation(t *testing.T) {
+	history := []Message{
+		{Role: "user", Content: "<|eot_id|> first"},
+		{Role: "assistant", Content: "first"},
+	}
+
+	tests := []struct {
+		name      string
+		modelName string
+		want      []Message
+	}{
+		{
+			name:      "default model",
+			modelName: "gpt-4",
+			want: []Message{
+				{Role: "system", Content: systemConstraints},
+				{Role: "user", Content: userConstraints + ": <|eot_id|> first"},
+				{Role: "assistant", Content: "first"},
+				{Role: "user", Content: userConstraints + ": second"},
+			},
+		},
+		{
+			name:      "hermes model",
+			modelName: "hermes-3",
+			want: []Message{
+				{Role: "user", Content: userConstraints + ": <|eot_id|> first"},
+				{Role: "assistant", Content: "first"},
+				{Role: "user", Content: userConstraints + ": second"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, strategy := DefaultStrategyRegistry().Lookup(tt.modelName)
+			got := strategy.Build(tt.modelName, history, "second")
+			if len(got.Messages) != len(tt.want) {
+				t.Fatalf("Build().Messages length = %v, want %v", len(got.Messages), len(tt.want))
+			}
+			for i, msg := range got.Messages {
+				if msg != tt.want[i] {
+					t.Errorf("Build().Messages[%d] = %v, want %v", i, msg, tt.want[i])
 				}
 			}
 		})
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Sampling holds the decoding parameters sent with every query. Unset (nil) parameters are left
+// to the prompt strategy (temperature, top_p, max_tokens) or to the API server (others).
+type Sampling struct {
+	Temperature   *float64 `json:"temperature,omitempty"`
+	TopP          *float64 `json:"top_p,omitempty"`
+	TopK          *int     `json:"top_k,omitempty"`
+	Seed          *int64   `json:"seed,omitempty"`
+	MaxTokens     *int     `json:"max_tokens,omitempty"`
+	RepeatPenalty *float64 `json:"repeat_penalty,omitempty"`
+	Stop          []string `json:"stop,omitempty"`
+}
+
+// LoadSampling reads a JSON file holding sampling parameters
+func LoadSampling(filePath string) (Sampling, error) {
+	var sampling Sampling
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return sampling, fmt.Errorf("failed to read the sampling file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &sampling); err != nil {
+		return sampling, fmt.Errorf("failed to unmarshal the sampling file: %w", err)
+	}
+
+	return sampling, sampling.Validate()
+}
+
+// Validate checks that the parameters are within the ranges accepted by the API servers
+func (s Sampling) Validate() error {
+	if s.Temperature != nil && *s.Temperature < 0 {
+		return fmt.Errorf("temperature must be positive, got %v", *s.Temperature)
+	}
+	if s.TopP != nil && (*s.TopP <= 0 || *s.TopP > 1) {
+		return fmt.Errorf("top_p must be in ]0, 1], got %v", *s.TopP)
+	}
+	if s.TopK != nil && *s.TopK < 0 {
+		return fmt.Errorf("top_k must be positive, got %d", *s.TopK)
+	}
+	if s.MaxTokens != nil && *s.MaxTokens <= 0 {
+		return fmt.Errorf("max_tokens must be strictly positive, got %d", *s.MaxTokens)
+	}
+	if s.RepeatPenalty != nil && *s.RepeatPenalty <= 0 {
+		return fmt.Errorf("repeat_penalty must be strictly positive, got %v", *s.RepeatPenalty)
+	}
+	return nil
+}
+
+// apply overrides the parameters of the prompt with the parameters set
+func (s Sampling) apply(prompt *Prompt) {
+	if s.Temperature != nil {
+		prompt.Temperature = *s.Temperature
+	}
+	if s.TopP != nil {
+		prompt.TopP = s.TopP
+	}
+	if s.TopK != nil {
+		prompt.TopK = s.TopK
+	}
+	if s.Seed != nil {
+		prompt.Seed = s.Seed
+	}
+	if s.MaxTokens != nil {
+		prompt.MaxTokens = s.MaxTokens
+	}
+	if s.RepeatPenalty != nil {
+		prompt.RepeatPenalty = s.RepeatPenalty
+	}
+	if len(s.Stop) > 0 {
+		prompt.Stop = append([]string{}, s.Stop...)
+	}
+}
+
+// String lists the parameters set, e.g. "temperature=0 seed=42"
+func (s Sampling) String() string {
+	var parameters []string
+	if s.Temperature != nil {
+		parameters = append(parameters, fmt.Sprintf("temperature=%v", *s.Temperature))
+	}
+	if s.TopP != nil {
+		parameters = append(parameters, fmt.Sprintf("top_p=%v", *s.TopP))
+	}
+	if s.TopK != nil {
+		parameters = append(parameters, fmt.Sprintf("top_k=%d", *s.TopK))
+	}
+	if s.Seed != nil {
+		parameters = append(parameters, fmt.Sprintf("seed=%d", *s.Seed))
+	}
+	if s.MaxTokens != nil {
+		parameters = append(parameters, fmt.Sprintf("max_tokens=%d", *s.MaxTokens))
+	}
+	if s.RepeatPenalty != nil {
+		parameters = append(parameters, fmt.Sprintf("repeat_penalty=%v", *s.RepeatPenalty))
+	}
+	if len(s.Stop) > 0 {
+		parameters = append(parameters, fmt.Sprintf("stop=%q", s.Stop))
+	}
+	return strings.Join(parameters, " ")
+}
+
+// Sampling returns the effective sampling parameters of the prompt
+func (p Prompt) Sampling() Sampling {
+	temperature := p.Temperature
+	return Sampling{
+		Temperature:   &temperature,
+		TopP:          p.TopP,
+		TopK:          p.TopK,
+		Seed:          p.Seed,
+		MaxTokens:     p.MaxTokens,
+		RepeatPenalty: p.RepeatPenalty,
+		Stop:          p.Stop,
+	}
+}
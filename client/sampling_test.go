@@ -0,0 +1,130 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSamplingValidate(t *testing.T) {
+	negative, zero, tooHigh := -1.0, 0.0, 1.5
+	zeroTokens := 0
+
+	tests := []struct {
+		name        string
+		sampling    Sampling
+		expectError bool
+	}{
+		{name: "empty", sampling: Sampling{}},
+		{name: "greedy", sampling: Sampling{Temperature: &zero}},
+		{name: "negative temperature", sampling: Sampling{Temperature: &negative}, expectError: true},
+		{name: "top_p out of range", sampling: Sampling{TopP: &tooHigh}, expectError: true},
+		{name: "no tokens", sampling: Sampling{MaxTokens: &zeroTokens}, expectError: true},
+		{name: "zero repeat penalty", sampling: Sampling{RepeatPenalty: &zero}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.sampling.Validate()
+			if tt.expectError && err == nil {
+				t.Errorf("expected an error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoadSampling(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "sampling.json")
+	content := `{"temperature": 0, "seed": 42, "max_tokens": 256, "stop": ["<|eot_id|>"]}`
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write sampling file: %v", err)
+	}
+
+	sampling, err := LoadSampling(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := `temperature=0 seed=42 max_tokens=256 stop=["<|eot_id|>"]`
+	if got := sampling.String(); got != want {
+		t.Errorf("String() = %v, want %v", got, want)
+	}
+}
+
+func TestQuerySendsSampling(t *testing.T) {
+	temperature, topP := 0.0, 0.9
+	topK, maxTokens := 1, 64
+	seed := int64(7)
+	repeatPenalty := 1.1
+
+	var received map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(Response{Choices: []Choice{{Message: Message{Content: "response"}}}})
+	}))
+	defer server.Close()
+
+	client := &Client{queryURL: server.URL, httpClient: &http.Client{}}
+	client.UseSampling(Sampling{
+		Temperature:   &temperature,
+		TopP:          &topP,
+		TopK:          &topK,
+		Seed:          &seed,
+		MaxTokens:     &maxTokens,
+		RepeatPenalty: &repeatPenalty,
+		Stop:          []string{"</s>"},
+	})
+
+	if _, err := client.Query("model_1", "hello"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]any{
+		"temperature":    0.0,
+		"top_p":          0.9,
+		"top_k":          1.0,
+		"seed":           7.0,
+		"max_tokens":     64.0,
+		"repeat_penalty": 1.1,
+	}
+	for key, value := range want {
+		if received[key] != value {
+			t.Errorf("request %s = %v, want %v", key, received[key], value)
+		}
+	}
+	if stop, ok := received["stop"].([]any); !ok || len(stop) != 1 || stop[0] != "</s>" {
+		t.Errorf("request stop = %v, want [</s>]", received["stop"])
+	}
+}
+
+func TestQueryOmitsUnsetSampling(t *testing.T) {
+	prompt := defaultStrategy("system").Build("model_1", nil, "hello")
+	Sampling{}.apply(&prompt)
+
+	data, err := json.Marshal(prompt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, key := range []string{"top_p", "top_k", "seed", "max_tokens", "repeat_penalty", "stop"} {
+		if _, ok := fields[key]; ok {
+			t.Errorf("unset parameter %s must be omitted", key)
+		}
+	}
+	if fields["temperature"] != defaultTemperature {
+		t.Errorf("temperature = %v, want %v", fields["temperature"], defaultTemperature)
+	}
+}
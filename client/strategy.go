@@ -0,0 +1,227 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// messagePlaceholder is replaced by the message content in user templates
+	messagePlaceholder = "{message}"
+	// messageJSONPlaceholder is replaced by the message content as a JSON string literal
+	messageJSONPlaceholder = "{message_json}"
+
+	defaultTemperature = 0.8
+)
+
+// PromptStrategy decides how a message and its conversation history are laid out for a model
+type PromptStrategy interface {
+	// Build returns the prompt sent to the model
+	Build(modelName string, history []Message, content string) Prompt
+	// Instructions returns the instructions given to the model, by role,
+	// so that their reproduction in a response can be detected
+	Instructions() map[string]string
+}
+
+// TemplateStrategy is a PromptStrategy made of an optional system message followed by
+// user messages rendered from a template
+type TemplateStrategy struct {
+	// SystemRole is the role of the message holding the system text (no system message when empty)
+	SystemRole string
+	SystemText string
+	// UserTemplate is the user message, in which {message} is replaced by the content
+	// and {message_json} by the content as a JSON string literal
+	UserTemplate string
+	Temperature  float64
+	// TopP and MaxTokens are left to the API server when nil
+	TopP      *float64
+	MaxTokens *int
+}
+
+// Build inserts the history between the system message and the final user message.
+// User turns of the history are rendered with the same template as the final message.
+func (s *TemplateStrategy) Build(modelName string, history []Message, content string) Prompt {
+	var messages []Message
+	if s.SystemRole != "" {
+		messages = append(messages, Message{Role: s.SystemRole, Content: s.SystemText})
+	}
+
+	for _, turn := range history {
+		if turn.Role == "user" {
+			messages = append(messages, s.userMessage(turn.Content))
+		} else {
+			messages = append(messages, turn)
+		}
+	}
+	messages = append(messages, s.userMessage(content))
+
+	return Prompt{
+		Model:       strings.TrimSpace(strings.ToLower(modelName)),
+		Messages:    messages,
+		Temperature: s.Temperature,
+		Stream:      false,
+		TopP:        s.TopP,
+		MaxTokens:   s.MaxTokens,
+	}
+}
+
+// Instructions returns the system text and the user template stripped from its placeholder
+func (s *TemplateStrategy) Instructions() map[string]string {
+	instructions := map[string]string{
+		"user": strings.TrimSpace(renderTemplate(s.UserTemplate, "")),
+	}
+	if s.SystemRole != "" && s.SystemText != "" {
+		instructions["system"] = s.SystemText
+	}
+	return instructions
+}
+
+func (s *TemplateStrategy) userMessage(content string) Message {
+	return Message{
+		Role:    "user",
+		Content: renderTemplate(s.UserTemplate, content),
+	}
+}
+
+func renderTemplate(template, content string) string {
+	if strings.Contains(template, messageJSONPlaceholder) {
+		// an empty content removes the placeholder (see Instructions)
+		var literal string
+		if content != "" {
+			var buf bytes.Buffer
+			encoder := json.NewEncoder(&buf)
+			// keep `<`, `>` and `&` as is: escaping them would hide the delimiters
+			encoder.SetEscapeHTML(false)
+			if err := encoder.Encode(content); err != nil {
+				literal = fmt.Sprintf("%q", content)
+			} else {
+				literal = strings.TrimSpace(buf.String())
+			}
+		}
+		template = strings.ReplaceAll(template, messageJSONPlaceholder, literal)
+	}
+	return strings.ReplaceAll(template, messagePlaceholder, content)
+}
+
+// StrategyConfig is the description of a TemplateStrategy in a strategies file.
+// Absent fields take the values of the default echo strategy.
+type StrategyConfig struct {
+	Name string `json:"name"`
+	// Pattern is matched, case-insensitively, as a substring of the model name ("" or "*" match any model)
+	Pattern      string   `json:"pattern"`
+	SystemRole   *string  `json:"system_role"`
+	SystemText   *string  `json:"system_text"`
+	UserTemplate *string  `json:"user_template"`
+	Temperature  *float64 `json:"temperature"`
+	TopP         *float64 `json:"top_p"`
+	MaxTokens    *int     `json:"max_tokens"`
+}
+
+// validate checks the sampling parameters of the strategy (see Sampling.Validate)
+func (c StrategyConfig) validate() error {
+	return Sampling{Temperature: c.Temperature, TopP: c.TopP, MaxTokens: c.MaxTokens}.Validate()
+}
+
+func (c StrategyConfig) strategy() *TemplateStrategy {
+	strategy := defaultStrategy("system")
+	if c.SystemRole != nil {
+		strategy.SystemRole = *c.SystemRole
+	}
+	if c.SystemText != nil {
+		strategy.SystemText = *c.SystemText
+	}
+	if c.UserTemplate != nil {
+		strategy.UserTemplate = *c.UserTemplate
+	}
+	if c.Temperature != nil {
+		strategy.Temperature = *c.Temperature
+	}
+	strategy.TopP = c.TopP
+	strategy.MaxTokens = c.MaxTokens
+	return strategy
+}
+
+type registeredStrategy struct {
+	name     string
+	pattern  string
+	strategy PromptStrategy
+}
+
+// StrategyRegistry selects the prompt strategy of a model from its name.
+// Strategies are tried in registration order; the first one whose pattern matches is used.
+type StrategyRegistry struct {
+	strategies []registeredStrategy
+}
+
+func defaultStrategy(systemRole string) *TemplateStrategy {
+	return &TemplateStrategy{
+		SystemRole:   systemRole,
+		SystemText:   systemConstraints,
+		UserTemplate: userConstraints + ": " + messagePlaceholder,
+		Temperature:  defaultTemperature,
+	}
+}
+
+// DefaultStrategyRegistry returns the built-in strategies
+func DefaultStrategyRegistry() *StrategyRegistry {
+	registry := &StrategyRegistry{}
+	// mistral models do not support the system role
+	registry.Register("mistral", "mistral", defaultStrategy("assistant"))
+	registry.Register("hermes-3", "hermes-3", defaultStrategy(""))
+	registry.Register("default", "*", defaultStrategy("system"))
+	return registry
+}
+
+// LoadStrategyRegistry reads a JSON strategies file. The strategies of the file take precedence
+// over the built-in ones, which remain available as fallbacks.
+func LoadStrategyRegistry(filePath string) (*StrategyRegistry, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the strategies file: %w", err)
+	}
+
+	var file struct {
+		Strategies []StrategyConfig `json:"strategies"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the strategies file: %w", err)
+	}
+
+	registry := &StrategyRegistry{}
+	for i, config := range file.Strategies {
+		name := config.Name
+		if name == "" {
+			name = fmt.Sprintf("strategy #%d", i+1)
+		}
+		if err := config.validate(); err != nil {
+			return nil, fmt.Errorf("invalid strategy %s: %w", name, err)
+		}
+		registry.Register(name, config.Pattern, config.strategy())
+	}
+	registry.strategies = append(registry.strategies, DefaultStrategyRegistry().strategies...)
+
+	return registry, nil
+}
+
+// Register adds a strategy used for the models whose name contains the pattern
+func (r *StrategyRegistry) Register(name, pattern string, strategy PromptStrategy) {
+	r.strategies = append(r.strategies, registeredStrategy{
+		name:     name,
+		pattern:  strings.TrimSpace(strings.ToLower(pattern)),
+		strategy: strategy,
+	})
+}
+
+// Lookup returns the name and the strategy to use for the model
+func (r *StrategyRegistry) Lookup(modelName string) (string, PromptStrategy) {
+	modelName = strings.TrimSpace(strings.ToLower(modelName))
+	for _, registered := range r.strategies {
+		if registered.pattern == "" || registered.pattern == "*" || strings.Contains(modelName, registered.pattern) {
+			return registered.name, registered.strategy
+		}
+	}
+	return "default", defaultStrategy("system")
+}
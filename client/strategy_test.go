@@ -0,0 +1,169 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaultStrategyRegistryLookup(t *testing.T) {
+	tests := []struct {
+		modelName string
+		wantName  string
+	}{
+		{modelName: "Mistral-7B-Instruct", wantName: "mistral"},
+		{modelName: "hermes-3-llama-3.1-8b", wantName: "hermes-3"},
+		{modelName: "llama-3.2-3b-instruct", wantName: "default"},
+	}
+
+	registry := DefaultStrategyRegistry()
+	for _, tt := range tests {
+		t.Run(tt.modelName, func(t *testing.T) {
+			name, strategy := registry.Lookup(tt.modelName)
+			if name != tt.wantName {
+				t.Errorf("Lookup() name = %v, want %v", name, tt.wantName)
+			}
+			if strategy == nil {
+				t.Fatal("Lookup() returned a nil strategy")
+			}
+		})
+	}
+}
+
+func TestLoadStrategyRegistry(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "strategies.json")
+	content := `{
+  "strategies": [
+    {
+      "name": "terse-qwen",
+      "pattern": "qwen",
+      "system_text": "Echo.",
+      "user_template": "<echo>{message}</echo>",
+      "temperature": 0,
+      "top_p": 0.9,
+      "max_tokens": 256
+    },
+    {
+      "name": "no-system-llama",
+      "pattern": "llama",
+      "system_role": ""
+    }
+  ]
+}`
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write strategies file: %v", err)
+	}
+
+	registry, err := LoadStrategyRegistry(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	name, strategy := registry.Lookup("Qwen2.5-7B")
+	if name != "terse-qwen" {
+		t.Fatalf("Lookup() name = %v, want terse-qwen", name)
+	}
+	prompt := strategy.Build("Qwen2.5-7B", []Message{{Role: "user", Content: "first"}, {Role: "assistant", Content: "first"}}, "second")
+	want := []Message{
+		{Role: "system", Content: "Echo."},
+		{Role: "user", Content: "<echo>first</echo>"},
+		{Role: "assistant", Content: "first"},
+		{Role: "user", Content: "<echo>second</echo>"},
+	}
+	if len(prompt.Messages) != len(want) {
+		t.Fatalf("Build().Messages length = %v, want %v", len(prompt.Messages), len(want))
+	}
+	for i, msg := range prompt.Messages {
+		if msg != want[i] {
+			t.Errorf("Build().Messages[%d] = %v, want %v", i, msg, want[i])
+		}
+	}
+	if prompt.Temperature != 0 {
+		t.Errorf("Build().Temperature = %v, want 0", prompt.Temperature)
+	}
+	if prompt.TopP == nil || *prompt.TopP != 0.9 || prompt.MaxTokens == nil || *prompt.MaxTokens != 256 {
+		t.Errorf("Build() sampling = %s, want top_p=0.9 max_tokens=256", prompt.Sampling())
+	}
+	if prompt.Model != "qwen2.5-7b" {
+		t.Errorf("Build().Model = %v, want qwen2.5-7b", prompt.Model)
+	}
+
+	instructions := strategy.Instructions()
+	if instructions["system"] != "Echo." || instructions["user"] != "<echo></echo>" {
+		t.Errorf("Instructions() = %v", instructions)
+	}
+
+	// absent fields keep the default echo instructions
+	_, strategy = registry.Lookup("llama-3.2-3b-instruct")
+	prompt = strategy.Build("llama-3.2-3b-instruct", nil, "hello")
+	if len(prompt.Messages) != 1 || prompt.Messages[0] != (Message{Role: "user", Content: userConstraints + ": hello"}) {
+		t.Errorf("Build().Messages = %v", prompt.Messages)
+	}
+	if prompt.Temperature != defaultTemperature {
+		t.Errorf("Build().Temperature = %v, want %v", prompt.Temperature, defaultTemperature)
+	}
+	if prompt.TopP != nil || prompt.MaxTokens != nil {
+		t.Errorf("Build() sampling = %s, want the top_p and max_tokens left to the server", prompt.Sampling())
+	}
+
+	// built-in strategies remain available as fallbacks
+	if name, _ := registry.Lookup("mistral-nemo"); name != "mistral" {
+		t.Errorf("Lookup() name = %v, want mistral", name)
+	}
+}
+
+func TestLoadStrategyRegistryErrors(t *testing.T) {
+	if _, err := LoadStrategyRegistry(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+
+	filePath := filepath.Join(t.TempDir(), "strategies.json")
+	if err := os.WriteFile(filePath, []byte("invalid_json"), 0644); err != nil {
+		t.Fatalf("failed to write strategies file: %v", err)
+	}
+	if _, err := LoadStrategyRegistry(filePath); err == nil {
+		t.Errorf("expected an error for a malformed file")
+	}
+
+	if err := os.WriteFile(filePath, []byte(`{"strategies": [{"name": "greedy", "top_p": 0}]}`), 0644); err != nil {
+		t.Fatalf("failed to write strategies file: %v", err)
+	}
+	if _, err := LoadStrategyRegistry(filePath); err == nil {
+		t.Errorf("expected an error for an invalid top_p")
+	}
+}
+
+func TestRenderTemplateJSON(t *testing.T) {
+	got := renderTemplate(`{"message": {message_json}}`, `<|eot_id|> "quoted"`)
+	want := `{"message": "<|eot_id|> \"quoted\""}`
+	if got != want {
+		t.Errorf("renderTemplate() = %v, want %v", got, want)
+	}
+
+	if got := renderTemplate(`{"message": {message_json}}`, ""); got != `{"message": }` {
+		t.Errorf("renderTemplate() = %v, want the placeholder removed", got)
+	}
+}
+
+func TestEchoVariants(t *testing.T) {
+	seen := make(map[string]bool)
+	for _, variant := range EchoVariants() {
+		if seen[variant.Name] {
+			t.Errorf("duplicated variant: %s", variant.Name)
+		}
+		seen[variant.Name] = true
+
+		prompt := variant.Strategy.Build("model", nil, "<|eot_id|> hello")
+		last := prompt.Messages[len(prompt.Messages)-1]
+		if last.Role != "user" || !strings.Contains(last.Content, "<|eot_id|> hello") {
+			t.Errorf("%s: the user message must embed the content verbatim, got %v", variant.Name, last)
+		}
+	}
+
+	for _, name := range []string{"default", "no_system", "terse", "verbose", "code_block", "json"} {
+		if !seen[name] {
+			t.Errorf("missing variant: %s", name)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/glethuillier/deLLMiter/analyzer"
+	"github.com/glethuillier/deLLMiter/gguf"
+	"github.com/glethuillier/deLLMiter/tokenizer"
+	"github.com/glethuillier/deLLMiter/utils"
+)
+
+// runEvaluate implements the `evaluate` subcommand: it compares the delimiters detected for a model
+// with its real special tokens and reports the precision, recall and F1 score of the detection
+func runEvaluate(args []string) error {
+	flags := flag.NewFlagSet("evaluate", flag.ExitOnError)
+	modelName := flags.String("model", "", "The name of the model whose detected delimiters are evaluated (required unless -detected is set).")
+	detectedFile := flags.String("detected", "", "The file of detected delimiters (optional, defaults to ./results/{model}_delimiters.txt).")
+	truth := flags.String("truth", "", "The ground truth: a delimiters file, a GGUF file or a directory of tokenizer files (required).")
+	testedFile := flags.String("tested", "", "The file of the delimiters sent to the model; other special tokens are ignored (optional).")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s evaluate [flags]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *truth == "" || (*modelName == "" && *detectedFile == "") {
+		flags.Usage()
+		return fmt.Errorf("a ground truth and a model or a detected delimiters file are required")
+	}
+
+	if *detectedFile == "" {
+		*detectedFile = utils.DelimitersFilePath(*modelName)
+	}
+	detected, err := utils.LoadDelimiters(*detectedFile)
+	if err != nil {
+		return err
+	}
+
+	truthTokens, err := loadGroundTruth(*truth)
+	if err != nil {
+		return err
+	}
+
+	var tested []string
+	if *testedFile != "" {
+		if tested, err = utils.LoadDelimiters(*testedFile); err != nil {
+			return err
+		}
+	}
+
+	evaluation := analyzer.Evaluate(detected, truthTokens, tested)
+	if err := evaluation.Report(os.Stdout); err != nil {
+		return fmt.Errorf("failed to print the evaluation: %w", err)
+	}
+
+	if *modelName != "" {
+		return utils.SaveFile(utils.ResultPath(*modelName, "evaluation.txt"), false, evaluation.Report)
+	}
+	return nil
+}
+
+// loadGroundTruth reads the real special tokens of a model from a delimiters file,
+// a GGUF file or a directory of Hugging Face tokenizer files
+func loadGroundTruth(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the ground truth: %w", err)
+	}
+
+	switch {
+	case info.IsDir():
+		delimiters, err := tokenizer.Import(path)
+		if err != nil {
+			return nil, err
+		}
+		tokens := make([]string, 0, len(delimiters))
+		for _, delimiter := range delimiters {
+			tokens = append(tokens, delimiter.Token)
+		}
+		return tokens, nil
+
+	case strings.EqualFold(filepath.Ext(path), ".gguf"):
+		metadata, err := gguf.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		special, err := metadata.SpecialTokens()
+		if err != nil {
+			return nil, err
+		}
+		tokens := make([]string, 0, len(special))
+		for _, token := range special {
+			tokens = append(tokens, token.Text)
+		}
+		return tokens, nil
+
+	default:
+		return utils.LoadDelimiters(path)
+	}
+}
@@ -0,0 +1,90 @@
+package experiment
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/glethuillier/deLLMiter/analyzer"
+	"github.com/glethuillier/deLLMiter/client"
+	"github.com/glethuillier/deLLMiter/generator"
+)
+
+// Ablation replays the same candidates under each echo instruction variant,
+// returning one result per variant, in the order of the variants
+func Ablation(candidates []generator.Candidate, variants []client.EchoVariant, analysis Analysis,
+	queryWith func(strategy client.PromptStrategy) QueryFunc) ([]Result, error) {
+	results := make([]Result, 0, len(variants))
+	for _, variant := range variants {
+		result, err := Replay(variant.Name, candidates, analysis, queryWith(variant.Strategy))
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// Best returns the result with the least noise: the highest echo fidelity,
+// then the lowest swallow rate. It returns false when no result has any response.
+func Best(results []Result) (Result, bool) {
+	var best Result
+	found := false
+	for _, result := range results {
+		if result.Candidates == 0 {
+			continue
+		}
+		if !found ||
+			result.Fidelity() > best.Fidelity() ||
+			(result.Fidelity() == best.Fidelity() && result.SwallowRate() < best.SwallowRate()) {
+			best, found = result, true
+		}
+	}
+	return best, found
+}
+
+// ReportAblation writes, for each echo instruction variant, the echo fidelity and the delimiter
+// swallow rates, followed by the least noisy variant
+func ReportAblation(w io.Writer, results []Result) error {
+	var builder strings.Builder
+	writer := tabwriter.NewWriter(&builder, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "Variant\tResponses\tFailures\tNon-compliant\tFidelity\tSwallow rate")
+	for _, result := range results {
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%d\t%.0f%%\t%.0f%%\n",
+			result.Label, result.Candidates, result.Failures, result.NonCompliant, 100*result.Fidelity(), 100*result.SwallowRate())
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to format the ablation report: %w", err)
+	}
+
+	for _, result := range results {
+		builder.WriteString(fmt.Sprintf("\n%s\n", result.Label))
+		builder.WriteString(formatDelimiterTallies(result.Delimiters))
+	}
+
+	if best, ok := Best(results); ok {
+		builder.WriteString(fmt.Sprintf("\nLeast noisy variant: %s\n", best.Label))
+	}
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// formatDelimiterTallies lists the swallow rate of each delimiter, in alphabetical order
+func formatDelimiterTallies(tallies map[string]*analyzer.DelimiterTally) string {
+	delimiters := make([]string, 0, len(tallies))
+	for d := range tallies {
+		delimiters = append(delimiters, d)
+	}
+	sort.Strings(delimiters)
+
+	var builder strings.Builder
+	for _, delimiter := range delimiters {
+		tally := tallies[delimiter]
+		builder.WriteString(fmt.Sprintf("  %s: %d/%d swallowed (%.0f%%)\n",
+			delimiter, tally.Swallowed, tally.Sent, 100*tally.SwallowRate()))
+	}
+	return builder.String()
+}
@@ -0,0 +1,108 @@
+package experiment
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/glethuillier/deLLMiter/analyzer"
+	"github.com/glethuillier/deLLMiter/generator"
+)
+
+// QueryFunc sends a candidate to the model and returns its response
+type QueryFunc func(candidate generator.Candidate) (string, error)
+
+// Result aggregates the verdicts of the analyzer over a replayed set of candidates
+type Result struct {
+	Label string
+	// Candidates is the number of candidates for which a response was received
+	Candidates int
+	Failures   int
+	// NonCompliant is the number of responses not echoing the candidate (refusals, off-task or empty responses)
+	NonCompliant int
+	// Echoed is the number of responses identical to the candidate
+	Echoed     int
+	Delimiters map[string]*analyzer.DelimiterTally
+	// Missing are the delimiters the analyzer reported as missing during the replay
+	Missing []string
+}
+
+// Fidelity returns the fraction of candidates echoed identically
+func (r Result) Fidelity() float64 {
+	if r.Candidates == 0 {
+		return 0
+	}
+	return float64(r.Echoed) / float64(r.Candidates)
+}
+
+// SwallowRate returns the fraction of all delimiter occurrences missing from the responses
+func (r Result) SwallowRate() float64 {
+	var total analyzer.DelimiterTally
+	for _, tally := range r.Delimiters {
+		total.Sent += tally.Sent
+		total.Swallowed += tally.Swallowed
+	}
+	return total.SwallowRate()
+}
+
+// Analysis configures the analysis of the replayed responses, as in probe mode
+type Analysis struct {
+	// Detectors are the names of the detectors run on each response
+	Detectors []string
+	// Normalizer is applied to the candidates and to the responses before comparing them
+	Normalizer analyzer.Normalizer
+}
+
+// Replay sends every candidate to the model and aggregates the analyzer verdicts under the given label.
+// Each response goes through the detector pipeline of the probe mode: the non-compliant responses are
+// counted but not analyzed. A fresh analyzer is used so that replays do not influence each other.
+func Replay(label string, candidates []generator.Candidate, analysis Analysis, query QueryFunc) (Result, error) {
+	a := analyzer.NewAnalyzer()
+	a.UseNormalizer(analysis.Normalizer)
+	pipeline, err := a.NewPipeline(analysis.Detectors)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to create the detector pipeline: %w", err)
+	}
+
+	result := Result{
+		Label:      label,
+		Delimiters: make(map[string]*analyzer.DelimiterTally),
+	}
+
+	for _, candidate := range candidates {
+		response, err := query(candidate)
+		if err != nil {
+			result.Failures++
+			continue
+		}
+		result.Candidates++
+
+		run := pipeline.Run(candidate, response)
+		if !run.Compliance.IsCompliant() {
+			result.NonCompliant++
+			continue
+		}
+		if run.Identical {
+			result.Echoed++
+		}
+		for _, delimiter := range a.Verdicts() {
+			if !slices.Contains(result.Missing, delimiter) {
+				result.Missing = append(result.Missing, delimiter)
+			}
+		}
+
+		for delimiter, tally := range a.TallyDelimiters(candidate, run.Echo) {
+			total := result.Delimiters[delimiter]
+			if total == nil {
+				total = &analyzer.DelimiterTally{}
+				result.Delimiters[delimiter] = total
+			}
+			total.Sent += tally.Sent
+			total.Swallowed += tally.Swallowed
+		}
+	}
+
+	sort.Strings(result.Missing)
+
+	return result, nil
+}
@@ -0,0 +1,138 @@
+package experiment
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/glethuillier/deLLMiter/analyzer"
+	"github.com/glethuillier/deLLMiter/client"
+	"github.com/glethuillier/deLLMiter/generator"
+)
+
+var testAnalysis = Analysis{Detectors: analyzer.DetectorNames(), Normalizer: analyzer.DefaultNormalizer()}
+
+var testCandidates = []generator.Candidate{
+	{
+		Message: "apple <|eot_id|> banana",
+		Items: []generator.Item{
+			{Type: generator.Expression, Token: "apple"},
+			{Type: generator.Delimiter, Token: "<|eot_id|>"},
+			{Type: generator.Expression, Token: "banana"},
+		},
+	},
+	{
+		Message: "[INST] cherry",
+		Items: []generator.Item{
+			{Type: generator.Delimiter, Token: "[INST]"},
+			{Type: generator.Expression, Token: "cherry"},
+		},
+	},
+}
+
+func TestReplay(t *testing.T) {
+	// the model swallows <|eot_id|> and fails on the second candidate
+	query := func(candidate generator.Candidate) (string, error) {
+		if strings.HasPrefix(candidate.Message, "[INST]") {
+			return "", errors.New("timeout")
+		}
+		return strings.ReplaceAll(candidate.Message, "<|eot_id|> ", ""), nil
+	}
+
+	result, err := Replay("swallowing", testCandidates, testAnalysis, query)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.Label != "swallowing" || result.Candidates != 1 || result.Failures != 1 || result.Echoed != 0 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	tally := result.Delimiters["<|eot_id|>"]
+	if tally == nil || tally.Sent != 1 || tally.Swallowed != 1 {
+		t.Fatalf("unexpected tally: %+v", tally)
+	}
+	if result.Fidelity() != 0 || result.SwallowRate() != 1 {
+		t.Errorf("unexpected rates: fidelity %v, swallow %v", result.Fidelity(), result.SwallowRate())
+	}
+}
+
+func TestReplayAnalyzesTheEcho(t *testing.T) {
+	// the first echo is wrapped and in upper case, the second candidate is refused
+	query := func(candidate generator.Candidate) (string, error) {
+		if strings.HasPrefix(candidate.Message, "[INST]") {
+			return "I'm sorry, I can't help with that.", nil
+		}
+		return "Sure! Here it is:\n\n" + strings.ToUpper(candidate.Message), nil
+	}
+
+	result, err := Replay("wrapped", testCandidates, testAnalysis, query)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Candidates != 2 || result.NonCompliant != 1 || result.Echoed != 1 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if _, ok := result.Delimiters["[INST]"]; ok {
+		t.Errorf("expected the refused candidate to be left out of the tallies, got %+v", result.Delimiters)
+	}
+	if tally := result.Delimiters["<|eot_id|>"]; tally == nil || tally.Swallowed != 0 {
+		t.Errorf("expected the delimiter to be preserved, got %+v", tally)
+	}
+}
+
+func TestReplayRejectsUnknownDetectors(t *testing.T) {
+	query := func(candidate generator.Candidate) (string, error) { return candidate.Message, nil }
+	if _, err := Replay("unknown", testCandidates, Analysis{Detectors: []string{"magic"}}, query); err == nil {
+		t.Errorf("expected an error for an unknown detector")
+	}
+}
+
+func TestAblation(t *testing.T) {
+	variants := []client.EchoVariant{
+		{Name: "faithful", Strategy: &client.TemplateStrategy{UserTemplate: "{message}"}},
+		{Name: "lossy", Strategy: &client.TemplateStrategy{UserTemplate: "echo: {message}"}},
+	}
+
+	results, err := Ablation(testCandidates, variants, testAnalysis, func(strategy client.PromptStrategy) QueryFunc {
+		return func(candidate generator.Candidate) (string, error) {
+			prompt := strategy.Build("model", nil, candidate.Message)
+			if strings.HasPrefix(prompt.Messages[0].Content, "echo:") {
+				return "cherry", nil
+			}
+			return candidate.Message, nil
+		}
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(results) != 2 || results[0].Label != "faithful" || results[1].Label != "lossy" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if results[0].Fidelity() != 1 || results[0].SwallowRate() != 0 {
+		t.Errorf("unexpected faithful result: %+v", results[0])
+	}
+	if results[1].SwallowRate() != 1 {
+		t.Errorf("unexpected lossy swallow rate: %v", results[1].SwallowRate())
+	}
+
+	best, ok := Best(results)
+	if !ok || best.Label != "faithful" {
+		t.Errorf("expected the faithful variant to be the best, got %+v", best)
+	}
+
+	var report strings.Builder
+	if err := ReportAblation(&report, results); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(report.String(), "  [INST]: 1/1 swallowed (100%)") ||
+		!strings.HasSuffix(report.String(), "Least noisy variant: faithful\n") {
+		t.Errorf("unexpected ablation report:\n%s", report.String())
+	}
+}
+
+func TestBestWithoutResponses(t *testing.T) {
+	if _, ok := Best([]Result{{Label: "empty"}}); ok {
+		t.Errorf("expected no best result")
+	}
+}
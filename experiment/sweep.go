@@ -0,0 +1,91 @@
+package experiment
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/glethuillier/deLLMiter/generator"
+)
+
+// ParseTemperatures converts a comma-separated list of temperatures (e.g. "0,0.4,0.8") into values
+func ParseTemperatures(list string) ([]float64, error) {
+	var temperatures []float64
+	for _, value := range strings.Split(list, ",") {
+		temperature, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid temperature %q: %w", value, err)
+		}
+		if temperature < 0 {
+			return nil, fmt.Errorf("temperature must be positive, got %v", temperature)
+		}
+		temperatures = append(temperatures, temperature)
+	}
+	return temperatures, nil
+}
+
+// Sweep replays the same candidates at each temperature, returning one result per temperature,
+// in the order of the temperatures. Results are labelled with the temperature.
+func Sweep(candidates []generator.Candidate, temperatures []float64, analysis Analysis,
+	queryAt func(temperature float64) QueryFunc) ([]Result, error) {
+	results := make([]Result, 0, len(temperatures))
+	for _, temperature := range temperatures {
+		result, err := Replay(strconv.FormatFloat(temperature, 'g', -1, 64), candidates, analysis, queryAt(temperature))
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// sweepBarWidth is the width of the bars plotting the swallow rates in the sweep report
+const sweepBarWidth = 40
+
+// ReportSweep writes the echo fidelity at each temperature, then plots, for each delimiter,
+// its swallow rate versus the temperature
+func ReportSweep(w io.Writer, results []Result) error {
+	var builder strings.Builder
+	writer := tabwriter.NewWriter(&builder, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "Temperature\tResponses\tFailures\tNon-compliant\tFidelity\tSwallow rate\tMissing")
+	for _, result := range results {
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%d\t%.0f%%\t%.0f%%\t%v\n",
+			result.Label, result.Candidates, result.Failures, result.NonCompliant, 100*result.Fidelity(), 100*result.SwallowRate(), result.Missing)
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to format the sweep report: %w", err)
+	}
+
+	delimiters := make(map[string]struct{})
+	for _, result := range results {
+		for d := range result.Delimiters {
+			delimiters[d] = struct{}{}
+		}
+	}
+	sortedDelimiters := make([]string, 0, len(delimiters))
+	for d := range delimiters {
+		sortedDelimiters = append(sortedDelimiters, d)
+	}
+	sort.Strings(sortedDelimiters)
+
+	for _, delimiter := range sortedDelimiters {
+		builder.WriteString(fmt.Sprintf("\n%s\n", delimiter))
+		for _, result := range results {
+			tally, ok := result.Delimiters[delimiter]
+			if !ok {
+				builder.WriteString(fmt.Sprintf("  t=%-6s not sent\n", result.Label))
+				continue
+			}
+			filled := int(tally.SwallowRate()*sweepBarWidth + 0.5)
+			builder.WriteString(fmt.Sprintf("  t=%-6s |%s%s| %3.0f%% (%d/%d)\n",
+				result.Label, strings.Repeat("#", filled), strings.Repeat(" ", sweepBarWidth-filled),
+				100*tally.SwallowRate(), tally.Swallowed, tally.Sent))
+		}
+	}
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
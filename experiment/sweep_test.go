@@ -0,0 +1,61 @@
+package experiment
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/glethuillier/deLLMiter/generator"
+)
+
+func TestParseTemperatures(t *testing.T) {
+	temperatures, err := ParseTemperatures("0, 0.5,1.2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(temperatures) != 3 || temperatures[0] != 0 || temperatures[1] != 0.5 || temperatures[2] != 1.2 {
+		t.Errorf("unexpected temperatures: %v", temperatures)
+	}
+
+	for _, list := range []string{"0,hot", "-0.1"} {
+		if _, err := ParseTemperatures(list); err == nil {
+			t.Errorf("expected an error for %q", list)
+		}
+	}
+}
+
+func TestSweep(t *testing.T) {
+	// the model swallows <|eot_id|> only above 0.5
+	results, err := Sweep(testCandidates, []float64{0, 1}, testAnalysis, func(temperature float64) QueryFunc {
+		return func(candidate generator.Candidate) (string, error) {
+			if temperature > 0.5 {
+				return strings.ReplaceAll(candidate.Message, "<|eot_id|> ", ""), nil
+			}
+			return candidate.Message, nil
+		}
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(results) != 2 || results[0].Label != "0" || results[1].Label != "1" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if rate := results[0].Delimiters["<|eot_id|>"].SwallowRate(); rate != 0 {
+		t.Errorf("expected no swallow at temperature 0, got %v", rate)
+	}
+	if rate := results[1].Delimiters["<|eot_id|>"].SwallowRate(); rate != 1 {
+		t.Errorf("expected a full swallow at temperature 1, got %v", rate)
+	}
+	if rate := results[1].Delimiters["[INST]"].SwallowRate(); rate != 0 {
+		t.Errorf("expected [INST] to be preserved, got %v", rate)
+	}
+
+	var report strings.Builder
+	if err := ReportSweep(&report, results); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	bar := "  t=1      |" + strings.Repeat("#", sweepBarWidth) + "| 100% (1/1)\n"
+	if !strings.Contains(report.String(), "\n<|eot_id|>\n") || !strings.Contains(report.String(), bar) {
+		t.Errorf("unexpected sweep report:\n%s", report.String())
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/glethuillier/deLLMiter/gguf"
+)
+
+// runExtract implements the `extract` subcommand: it pulls the special tokens from the metadata
+// of a local GGUF file and writes them in the known_delimiters.txt format (one token per line)
+func runExtract(args []string) error {
+	flags := flag.NewFlagSet("extract", flag.ExitOnError)
+	output := flags.String("output", "", "The file to write the tokens to (optional, defaults to the standard output).")
+	chatTemplate := flags.String("chatTemplate", "", "A file to write the chat template of the model to (optional).")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s extract [flags] {model.gguf}\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("a GGUF file is required")
+	}
+
+	metadata, err := gguf.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	tokens, err := metadata.SpecialTokens()
+	if err != nil {
+		return err
+	}
+
+	var writer io.Writer = os.Stdout
+	if *output != "" {
+		file, createErr := os.Create(*output)
+		if createErr != nil {
+			return fmt.Errorf("failed to create the output file: %w", createErr)
+		}
+		defer func() {
+			if cerr := file.Close(); cerr != nil {
+				fmt.Printf("warning: failed to close file: %v\n", cerr)
+			}
+		}()
+		writer = file
+	}
+
+	written := 0
+	seen := make(map[string]struct{}, len(tokens))
+	for _, token := range tokens {
+		// the known delimiters format holds one token per line
+		if strings.ContainsAny(token.Text, "\r\n") || strings.TrimSpace(token.Text) == "" {
+			continue
+		}
+		if _, ok := seen[token.Text]; ok {
+			continue
+		}
+		seen[token.Text] = struct{}{}
+
+		if _, writeErr := fmt.Fprintln(writer, token.Text); writeErr != nil {
+			return fmt.Errorf("failed to write token: %w", writeErr)
+		}
+		written++
+	}
+
+	if *chatTemplate != "" {
+		template, ok := metadata.ChatTemplate()
+		if !ok {
+			return fmt.Errorf("no chat template found in the GGUF metadata")
+		}
+		if writeErr := os.WriteFile(*chatTemplate, []byte(template), 0644); writeErr != nil {
+			return fmt.Errorf("failed to write the chat template: %w", writeErr)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "%d special tokens extracted (%d found).\n", written, len(tokens))
+
+	return nil
+}
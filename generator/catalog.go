@@ -0,0 +1,214 @@
+package generator
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// CatalogDir is the default delimiter catalog directory
+	CatalogDir = "delimiters"
+	// BaseFamily is the family of the generic delimiters, always included
+	BaseFamily = "base"
+
+	catalogExtension = ".txt"
+	extendsDirective = "# extends:"
+)
+
+// familyPatterns detect the delimiter families of a model from its name
+var familyPatterns = []struct {
+	family  string
+	pattern *regexp.Regexp
+}{
+	{"llama3", regexp.MustCompile(`llama-?3`)},
+	{"mistral", regexp.MustCompile(`mistral|mixtral|ministral|codestral|devstral`)},
+	{"qwen", regexp.MustCompile(`qwen|qwq`)},
+	{"gemma", regexp.MustCompile(`gemma`)},
+	{"phi", regexp.MustCompile(`\bphi`)},
+	{"deepseek", regexp.MustCompile(`deepseek`)},
+	{"chatml", regexp.MustCompile(`hermes|dolphin|chatml|\byi\b`)},
+}
+
+// Catalog holds the known delimiters per model family. Each family is a file of the catalog directory
+// holding one delimiter per line, optionally followed by its role and its source URL (tab-separated).
+// A family may extend other families with a `# extends: family, ...` line.
+type Catalog struct {
+	families map[string][]KnownDelimiter
+	parents  map[string][]string
+}
+
+// LoadCatalog reads the families of a catalog directory
+func LoadCatalog(dir string) (*Catalog, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+catalogExtension))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the delimiter catalog: %w", err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no delimiter families found in %s", dir)
+	}
+
+	catalog := &Catalog{
+		families: make(map[string][]KnownDelimiter),
+		parents:  make(map[string][]string),
+	}
+	for _, file := range files {
+		family := strings.TrimSuffix(filepath.Base(file), catalogExtension)
+		if err := catalog.loadFamily(family, file); err != nil {
+			return nil, err
+		}
+	}
+
+	for family, parents := range catalog.parents {
+		for _, parent := range parents {
+			if _, ok := catalog.families[parent]; !ok {
+				return nil, fmt.Errorf("family %s extends unknown family %s", family, parent)
+			}
+		}
+	}
+
+	return catalog, nil
+}
+
+func (c *Catalog) loadFamily(family, filePath string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open the delimiter family %s: %w", family, err)
+	}
+	defer func() {
+		if cerr := file.Close(); cerr != nil {
+			fmt.Printf("warning: failed to close file: %v\n", cerr)
+		}
+	}()
+
+	delimiters := []KnownDelimiter{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if strings.HasPrefix(line, extendsDirective) {
+			for _, parent := range strings.Split(strings.TrimPrefix(line, extendsDirective), ",") {
+				if parent = strings.TrimSpace(parent); parent != "" {
+					c.parents[family] = append(c.parents[family], parent)
+				}
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		delimiter := KnownDelimiter{Token: fields[0], Family: family, Source: filePath}
+		if len(fields) > 1 {
+			delimiter.Role = strings.TrimSpace(fields[1])
+		}
+		if len(fields) > 2 {
+			delimiter.URL = strings.TrimSpace(fields[2])
+		}
+		delimiters = append(delimiters, delimiter)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading the delimiter family %s: %w", family, err)
+	}
+
+	c.families[family] = delimiters
+	return nil
+}
+
+// Families returns the names of the families of the catalog, sorted
+func (c *Catalog) Families() []string {
+	families := make([]string, 0, len(c.families))
+	for family := range c.families {
+		families = append(families, family)
+	}
+	sort.Strings(families)
+	return families
+}
+
+// Resolve returns the delimiters of the base family and of the given families, including the families
+// they extend. No family selects the whole catalog.
+func (c *Catalog) Resolve(families []string) ([]KnownDelimiter, error) {
+	if len(families) == 0 {
+		families = c.Families()
+	}
+
+	var delimiters []KnownDelimiter
+	visited := make(map[string]bool)
+
+	var visit func(family string) error
+	visit = func(family string) error {
+		if visited[family] {
+			return nil
+		}
+		visited[family] = true
+
+		familyDelimiters, ok := c.families[family]
+		if !ok {
+			return fmt.Errorf("unknown delimiter family: %s (available: %s)", family, strings.Join(c.Families(), ", "))
+		}
+		for _, parent := range c.parents[family] {
+			if err := visit(parent); err != nil {
+				return err
+			}
+		}
+		delimiters = append(delimiters, familyDelimiters...)
+		return nil
+	}
+
+	if _, ok := c.families[BaseFamily]; ok {
+		if err := visit(BaseFamily); err != nil {
+			return nil, err
+		}
+	}
+	for _, family := range families {
+		if err := visit(strings.TrimSpace(strings.ToLower(family))); err != nil {
+			return nil, err
+		}
+	}
+
+	return delimiters, nil
+}
+
+// DetectFamilies returns the delimiter families matching the name of a model, if any
+func DetectFamilies(modelName string) []string {
+	modelName = strings.ToLower(modelName)
+
+	var families []string
+	for _, familyPattern := range familyPatterns {
+		if familyPattern.pattern.MatchString(modelName) {
+			families = append(families, familyPattern.family)
+		}
+	}
+	return families
+}
+
+// ParseFamilies parses a comma-separated list of families
+func ParseFamilies(list string) []string {
+	var families []string
+	for _, family := range strings.Split(list, ",") {
+		if family = strings.TrimSpace(family); family != "" {
+			families = append(families, family)
+		}
+	}
+	return families
+}
+
+// NewGeneratorFromCatalog creates a generator from the delimiters of the given families of a catalog
+func NewGeneratorFromCatalog(logger *zap.Logger, catalog *Catalog, families []string) (*Generator, error) {
+	delimiters, err := catalog.Resolve(families)
+	if err != nil {
+		return nil, err
+	}
+	if len(delimiters) == 0 {
+		return nil, errors.New("no delimiters found in the selected families")
+	}
+	return NewGeneratorFromDelimiters(logger, delimiters)
+}
@@ -0,0 +1,165 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func writeCatalog(t *testing.T, families map[string]string) string {
+	dir := t.TempDir()
+	for family, content := range families {
+		if err := os.WriteFile(filepath.Join(dir, family+catalogExtension), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write family %s: %v", family, err)
+		}
+	}
+	return dir
+}
+
+func TestCatalogResolve(t *testing.T) {
+	dir := writeCatalog(t, map[string]string{
+		"base":   "# generic\n<s>\tbos\n</s>\teos\n",
+		"chatml": "<|im_start|>\theader\thttps://example.com/chatml\n<|im_end|>\teot\n",
+		"qwen":   "# extends: chatml\n<|endoftext|>\teos\n\n",
+		"llama3": "<|eot_id|>\n",
+	})
+
+	catalog, err := LoadCatalog(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		families  []string
+		expected  []string
+		expectErr bool
+	}{
+		{
+			name:     "inherited family",
+			families: []string{"qwen"},
+			expected: []string{"<s>", "</s>", "<|im_start|>", "<|im_end|>", "<|endoftext|>"},
+		},
+		{
+			name:     "several families",
+			families: []string{"llama3", "chatml", "qwen"},
+			expected: []string{"<s>", "</s>", "<|eot_id|>", "<|im_start|>", "<|im_end|>", "<|endoftext|>"},
+		},
+		{
+			name:     "whole catalog",
+			expected: []string{"<s>", "</s>", "<|im_start|>", "<|im_end|>", "<|eot_id|>", "<|endoftext|>"},
+		},
+		{
+			name:      "unknown family",
+			families:  []string{"gemma"},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			delimiters, err := catalog.Resolve(tc.families)
+			if tc.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var tokens []string
+			for _, delimiter := range delimiters {
+				tokens = append(tokens, delimiter.Token)
+			}
+			if !reflect.DeepEqual(tokens, tc.expected) {
+				t.Errorf("expected %q, got %q", tc.expected, tokens)
+			}
+		})
+	}
+}
+
+func TestCatalogMetadata(t *testing.T) {
+	dir := writeCatalog(t, map[string]string{
+		"chatml": "<|im_start|>\theader\thttps://example.com/chatml\n",
+	})
+
+	catalog, err := LoadCatalog(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	g, err := NewGeneratorFromCatalog(zap.NewNop(), catalog, []string{"chatml"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	provenance := g.Provenance("<|im_start|>")
+	expected := KnownDelimiter{
+		Token:  "<|im_start|>",
+		Role:   "header",
+		Source: filepath.Join(dir, "chatml.txt"),
+		Family: "chatml",
+		URL:    "https://example.com/chatml",
+	}
+	if len(provenance) != 1 || provenance[0] != expected {
+		t.Errorf("expected %+v, got %+v", expected, provenance)
+	}
+}
+
+func TestLoadCatalogErrors(t *testing.T) {
+	if _, err := LoadCatalog(t.TempDir()); err == nil {
+		t.Errorf("expected an error for an empty catalog, got none")
+	}
+
+	dir := writeCatalog(t, map[string]string{"qwen": "# extends: chatml\n<|endoftext|>\n"})
+	if _, err := LoadCatalog(dir); err == nil {
+		t.Errorf("expected an error for an unknown parent family, got none")
+	}
+}
+
+func TestDetectFamilies(t *testing.T) {
+	tests := []struct {
+		model    string
+		expected []string
+	}{
+		{"llama-3.2-3b-instruct", []string{"llama3"}},
+		{"Meta-Llama3-8B", []string{"llama3"}},
+		{"mistral-7b-instruct-v0.3", []string{"mistral"}},
+		{"qwen2.5-7b-instruct", []string{"qwen"}},
+		{"deepseek-r1-distill-qwen-7b", []string{"qwen", "deepseek"}},
+		{"phi-3-mini-4k-instruct", []string{"phi"}},
+		{"dolphin-2.9-llama2", []string{"chatml"}},
+		{"gemma-2-9b-it", []string{"gemma"}},
+		{"unknown-model", nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.model, func(t *testing.T) {
+			if families := DetectFamilies(tc.model); !reflect.DeepEqual(families, tc.expected) {
+				t.Errorf("expected %q, got %q", tc.expected, families)
+			}
+		})
+	}
+}
+
+func TestCatalogDirectory(t *testing.T) {
+	catalog, err := LoadCatalog(filepath.Join("..", CatalogDir))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, family := range []string{BaseFamily, "llama3", "mistral", "chatml", "gemma", "phi", "deepseek", "qwen"} {
+		delimiters, err := catalog.Resolve([]string{family})
+		if err != nil {
+			t.Errorf("unexpected error for family %s: %v", family, err)
+			continue
+		}
+		if len(delimiters) == 0 {
+			t.Errorf("expected delimiters for family %s, got none", family)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Role is the author of a conversation turn
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+)
+
+// TurnPlacement names the turns of the conversation history in which delimiters are placed
+type TurnPlacement string
+
+const (
+	PlaceInUser      TurnPlacement = "user"
+	PlaceInAssistant TurnPlacement = "assistant"
+	PlaceInBoth      TurnPlacement = "both"
+)
+
+// Turn is a message of the conversation history sent before the candidate message
+type Turn struct {
+	Role    Role
+	Message string
+	Items   []Item
+}
+
+// Delimiters returns the delimiters used or mentioned in the turn
+func (t Turn) Delimiters() []string {
+	var delimiters []string
+	for _, item := range t.Items {
+		switch item.Type {
+		case Delimiter:
+			delimiters = append(delimiters, item.Token)
+		case HigherOrder:
+			delimiters = append(delimiters, item.Delimiter)
+		}
+	}
+	return delimiters
+}
+
+// GenerateConversation composes a candidate preceded by policy.Turns user/assistant exchanges.
+// Each exchange is an echo: the assistant repeats the user message. Delimiters are only kept in the
+// turns selected by policy.TurnPlacement, the other side of the exchange containing the expressions alone.
+// The candidate message itself follows the policy, as with GenerateCandidate.
+func (g *Generator) GenerateConversation(policy GenerationPolicy) Candidate {
+	candidate := g.GenerateCandidate(policy)
+	if len(candidate.Items) == 0 {
+		return candidate
+	}
+
+	for i := 0; i < policy.Turns; i++ {
+		exchange := g.GenerateCandidate(policy)
+		clean := expressionsOnly(exchange)
+
+		user, assistant := clean, clean
+		switch policy.TurnPlacement {
+		case PlaceInAssistant:
+			assistant = exchange
+		case PlaceInBoth:
+			user, assistant = exchange, exchange
+		default:
+			user = exchange
+		}
+
+		candidate.History = append(candidate.History,
+			Turn{Role: RoleUser, Message: user.Message, Items: user.Items},
+			Turn{Role: RoleAssistant, Message: assistant.Message, Items: assistant.Items},
+		)
+	}
+
+	return candidate
+}
+
+// expressionsOnly returns the candidate stripped from its delimiters and higher-order expressions
+func expressionsOnly(candidate Candidate) Candidate {
+	var items []Item
+	var tokens []string
+	for _, item := range candidate.Items {
+		if item.Type == Expression {
+			items = append(items, item)
+			tokens = append(tokens, item.Token)
+		}
+	}
+	return Candidate{Message: strings.Join(tokens, " "), Items: items, Format: FormatPlain}
+}
+
+// ParseTurnPlacement validates a turn placement name
+func ParseTurnPlacement(name string) (TurnPlacement, error) {
+	placement := TurnPlacement(strings.TrimSpace(strings.ToLower(name)))
+	switch placement {
+	case PlaceInUser, PlaceInAssistant, PlaceInBoth:
+		return placement, nil
+	default:
+		return "", fmt.Errorf("unknown turn placement: %q", name)
+	}
+}
@@ -0,0 +1,76 @@
+package generator
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestGenerateConversation(t *testing.T) {
+	g := &Generator{
+		knownDelimiters: []string{"<|eot_id|>", "[INST]"},
+		logger:          zap.NewNop(),
+	}
+
+	tests := []struct {
+		name                string
+		placement           TurnPlacement
+		userDelimiters      bool
+		assistantDelimiters bool
+	}{
+		{name: "delimiters in user turns", placement: PlaceInUser, userDelimiters: true},
+		{name: "delimiters in assistant turns", placement: PlaceInAssistant, assistantDelimiters: true},
+		{name: "delimiters in both turns", placement: PlaceInBoth, userDelimiters: true, assistantDelimiters: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			policy := DefaultGenerationPolicy()
+			policy.DelimiterFirst = true
+			policy.Turns = 2
+			policy.TurnPlacement = tc.placement
+
+			candidate := g.GenerateConversation(policy)
+			if len(candidate.History) != 2*policy.Turns {
+				t.Fatalf("expected %d history turns, got %d", 2*policy.Turns, len(candidate.History))
+			}
+
+			for i, turn := range candidate.History {
+				wantRole, wantDelimiters := RoleUser, tc.userDelimiters
+				if i%2 == 1 {
+					wantRole, wantDelimiters = RoleAssistant, tc.assistantDelimiters
+				}
+				if turn.Role != wantRole {
+					t.Errorf("turn %d: expected role %s, got %s", i, wantRole, turn.Role)
+				}
+				if hasDelimiters := len(turn.Delimiters()) > 0; hasDelimiters != wantDelimiters {
+					t.Errorf("turn %d: expected delimiters: %v, got: %v", i, wantDelimiters, turn.Items)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateConversationWithoutTurns(t *testing.T) {
+	g := &Generator{
+		knownDelimiters: []string{"<|eot_id|>"},
+		logger:          zap.NewNop(),
+	}
+
+	candidate := g.GenerateConversation(DefaultGenerationPolicy())
+	if len(candidate.History) != 0 {
+		t.Errorf("expected no history, got %d turns", len(candidate.History))
+	}
+	if len(candidate.Items) == 0 {
+		t.Errorf("expected a candidate message")
+	}
+}
+
+func TestParseTurnPlacement(t *testing.T) {
+	if placement, err := ParseTurnPlacement(" Assistant"); err != nil || placement != PlaceInAssistant {
+		t.Errorf("expected %s, got %s (%v)", PlaceInAssistant, placement, err)
+	}
+	if _, err := ParseTurnPlacement("system"); err == nil {
+		t.Errorf("expected an error for an unknown placement")
+	}
+}
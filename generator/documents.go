@@ -0,0 +1,188 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/brianvoe/gofakeit/v7"
+)
+
+// Format names the container in which the items of a candidate are embedded
+type Format string
+
+const (
+	FormatPlain    Format = "plain"
+	FormatJSON     Format = "json"
+	FormatXML      Format = "xml"
+	FormatHTML     Format = "html"
+	FormatMarkdown Format = "markdown"
+	FormatCSV      Format = "csv"
+	FormatYAML     Format = "yaml"
+	FormatCode     Format = "code"
+)
+
+var documentRenderers = map[Format]func(items []Item) string{
+	FormatJSON:     renderJSON,
+	FormatXML:      renderXML,
+	FormatHTML:     renderHTML,
+	FormatMarkdown: renderMarkdownTable,
+	FormatCSV:      renderCSV,
+	FormatYAML:     renderYAML,
+	FormatCode:     renderCodeComments,
+}
+
+// ParseFormats converts a comma-separated list of format names (e.g. "plain,json") into formats
+func ParseFormats(list string) ([]Format, error) {
+	var formats []Format
+	for _, name := range strings.Split(list, ",") {
+		format := Format(strings.TrimSpace(strings.ToLower(name)))
+		if !format.isValid() {
+			return nil, fmt.Errorf("unknown format: %q", name)
+		}
+		formats = append(formats, format)
+	}
+	return formats, nil
+}
+
+func (f Format) isValid() bool {
+	_, ok := documentRenderers[f]
+	return ok || f == FormatPlain
+}
+
+// escapers return the form of a token in the formats that must escape some of its characters
+var escapers = map[Format]func(token string) string{
+	FormatJSON: func(token string) string {
+		literal := jsonString(token)
+		return literal[1 : len(literal)-1]
+	},
+	// quotes are doubled in quoted fields
+	FormatCSV: func(token string) string { return strings.ReplaceAll(token, `"`, `""`) },
+}
+
+// renderDocument embeds the items into a document of the given format.
+// Items are inserted verbatim: escaping them would hide the delimiters from the model. The formats
+// that must escape some characters (JSON and CSV strings) return a copy of the items recording the
+// escaped forms in Item.Rendered; the items passed are left untouched.
+func renderDocument(format Format, items []Item) (string, []Item) {
+	rendered := slices.Clone(items)
+	if escape, ok := escapers[format]; ok {
+		for i, item := range rendered {
+			if form := escape(item.Token); form != item.Token {
+				rendered[i].Rendered = form
+			}
+		}
+	}
+	return documentRenderers[format](items), rendered
+}
+
+// fieldNames returns distinct field names for a document with n fields
+func fieldNames(n int) []string {
+	names := make([]string, 0, n)
+	seen := make(map[string]struct{}, n)
+	for len(names) < n {
+		name := strings.ToLower(gofakeit.Noun())
+		if _, ok := seen[name]; ok || strings.ContainsAny(name, " \t\n") {
+			name = fmt.Sprintf("field_%d", len(names)+1)
+		}
+		seen[name] = struct{}{}
+		names = append(names, name)
+	}
+	return names
+}
+
+func renderJSON(items []Item) string {
+	names := fieldNames(len(items))
+
+	var builder strings.Builder
+	builder.WriteString("{\n")
+	for i, item := range items {
+		builder.WriteString(fmt.Sprintf("  %q: %s", names[i], jsonString(item.Token)))
+		if i < len(items)-1 {
+			builder.WriteString(",")
+		}
+		builder.WriteString("\n")
+	}
+	builder.WriteString("}")
+	return builder.String()
+}
+
+// jsonString returns the JSON string literal of a token, without escaping the HTML characters
+func jsonString(token string) string {
+	var value bytes.Buffer
+	encoder := json.NewEncoder(&value)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(token); err != nil {
+		return fmt.Sprintf("%q", token)
+	}
+	return strings.TrimSpace(value.String())
+}
+
+func renderXML(items []Item) string {
+	names := fieldNames(len(items))
+
+	var builder strings.Builder
+	builder.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<document>\n")
+	for i, item := range items {
+		builder.WriteString(fmt.Sprintf("  <%s>%s</%s>\n", names[i], item.Token, names[i]))
+	}
+	builder.WriteString("</document>")
+	return builder.String()
+}
+
+func renderHTML(items []Item) string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("<html>\n<head><title>%s</title></head>\n<body>\n", gofakeit.Word()))
+	for _, item := range items {
+		builder.WriteString(fmt.Sprintf("  <p>%s</p>\n", item.Token))
+	}
+	builder.WriteString("</body>\n</html>")
+	return builder.String()
+}
+
+func renderMarkdownTable(items []Item) string {
+	names := fieldNames(len(items))
+
+	var builder strings.Builder
+	builder.WriteString("| Key | Value |\n|-----|-------|\n")
+	for i, item := range items {
+		builder.WriteString(fmt.Sprintf("| %s | %s |\n", names[i], item.Token))
+	}
+	return strings.TrimSuffix(builder.String(), "\n")
+}
+
+func renderCSV(items []Item) string {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	records := [][]string{{"id", "value"}}
+	for i, item := range items {
+		records = append(records, []string{fmt.Sprintf("%d", i+1), item.Token})
+	}
+	if err := writer.WriteAll(records); err != nil {
+		return renderMarkdownTable(items)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func renderYAML(items []Item) string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("name: %s\nentries:\n", strings.ToLower(gofakeit.Word())))
+	for _, item := range items {
+		builder.WriteString(fmt.Sprintf("  - value: %s\n", item.Token))
+	}
+	return strings.TrimSuffix(builder.String(), "\n")
+}
+
+func renderCodeComments(items []Item) string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("func %s() {\n", strings.ToLower(gofakeit.Verb())))
+	for _, item := range items {
+		builder.WriteString(fmt.Sprintf("\t// %s\n", item.Token))
+	}
+	builder.WriteString("}")
+	return builder.String()
+}
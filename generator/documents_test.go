@@ -0,0 +1,148 @@
+package generator
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestRenderDocument(t *testing.T) {
+	items := []Item{
+		{Type: Expression, Token: "apple"},
+		{Type: Delimiter, Token: "<|eot_id|>"},
+		{Type: Expression, Token: "banana"},
+	}
+
+	tests := []struct {
+		format Format
+		check  func(document string) bool
+	}{
+		{
+			format: FormatJSON,
+			check: func(document string) bool {
+				var value map[string]string
+				return json.Unmarshal([]byte(document), &value) == nil && len(value) == len(items)
+			},
+		},
+		{
+			format: FormatXML,
+			check: func(document string) bool {
+				return strings.HasPrefix(document, "<?xml") && strings.HasSuffix(document, "</document>")
+			},
+		},
+		{
+			format: FormatHTML,
+			check: func(document string) bool {
+				return strings.Contains(document, "<p><|eot_id|></p>")
+			},
+		},
+		{
+			format: FormatMarkdown,
+			check: func(document string) bool {
+				return strings.Count(document, "\n") == len(items)+1
+			},
+		},
+		{
+			format: FormatCSV,
+			check: func(document string) bool {
+				records, err := csv.NewReader(strings.NewReader(document)).ReadAll()
+				return err == nil && len(records) == len(items)+1 && records[2][1] == "<|eot_id|>"
+			},
+		},
+		{
+			format: FormatYAML,
+			check: func(document string) bool {
+				return strings.Contains(document, "  - value: <|eot_id|>")
+			},
+		},
+		{
+			format: FormatCode,
+			check: func(document string) bool {
+				return strings.Contains(document, "\t// <|eot_id|>")
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(string(tc.format), func(t *testing.T) {
+			document, _ := renderDocument(tc.format, items)
+			for _, item := range items {
+				if !strings.Contains(document, item.Token) {
+					t.Fatalf("expected %q to be embedded verbatim in:\n%s", item.Token, document)
+				}
+			}
+			if !tc.check(document) {
+				t.Fatalf("unexpected %s document:\n%s", tc.format, document)
+			}
+		})
+	}
+}
+
+func TestRenderDocumentRecordsEscapedForms(t *testing.T) {
+	tests := []struct {
+		format   Format
+		rendered string
+	}{
+		{format: FormatJSON, rendered: `the token \"<|eot_id|>\" ends a turn`},
+		{format: FormatCSV, rendered: `the token ""<|eot_id|>"" ends a turn`},
+		{format: FormatXML},
+	}
+
+	for _, tc := range tests {
+		t.Run(string(tc.format), func(t *testing.T) {
+			items := []Item{
+				{Type: Expression, Token: "apple"},
+				{Type: HigherOrder, Token: `the token "<|eot_id|>" ends a turn`, Delimiter: "<|eot_id|>", Depth: 1},
+			}
+			document, rendered := renderDocument(tc.format, items)
+
+			if rendered[0].Rendered != "" {
+				t.Errorf("expected the expression to be inserted verbatim, got %q", rendered[0].Rendered)
+			}
+			if rendered[1].Rendered != tc.rendered {
+				t.Errorf("expected the rendered form %q, got %q", tc.rendered, rendered[1].Rendered)
+			}
+			if !strings.Contains(document, rendered[1].Form()) {
+				t.Errorf("expected %q to be found in:\n%s", rendered[1].Form(), document)
+			}
+			if items[1].Rendered != "" {
+				t.Errorf("expected the items passed to be left untouched, got %q", items[1].Rendered)
+			}
+		})
+	}
+}
+
+func TestParseFormats(t *testing.T) {
+	formats, err := ParseFormats("plain, JSON,yaml")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(formats) != 3 || formats[0] != FormatPlain || formats[1] != FormatJSON || formats[2] != FormatYAML {
+		t.Errorf("unexpected formats: %v", formats)
+	}
+
+	if _, err := ParseFormats("json,pdf"); err == nil {
+		t.Errorf("expected an error for an unknown format")
+	}
+}
+
+func TestGenerateCandidateFormat(t *testing.T) {
+	g := &Generator{
+		knownDelimiters: []string{"<|eot_id|>"},
+		logger:          zap.NewNop(),
+	}
+
+	policy := DefaultGenerationPolicy()
+	policy.Formats = []Format{FormatMarkdown}
+
+	candidate := g.GenerateCandidate(policy)
+	if candidate.Format != FormatMarkdown {
+		t.Fatalf("expected format %s, got %s", FormatMarkdown, candidate.Format)
+	}
+	if !strings.HasPrefix(candidate.Message, "| Key | Value |") {
+		t.Errorf("expected a markdown table, got:\n%s", candidate.Message)
+	}
+}
@@ -3,9 +3,7 @@ package generator
 import (
 	"bufio"
 	"errors"
-	"github.com/brianvoe/gofakeit/v7"
 	"go.uber.org/zap"
-	"math/rand"
 	"os"
 	"path/filepath"
 	"strings"
@@ -15,6 +13,7 @@ const knownDelimitersFilePath = "known_delimiters.txt"
 
 type Generator struct {
 	knownDelimiters []string
+	provenance      map[string][]KnownDelimiter
 	logger          *zap.Logger
 }
 
@@ -52,7 +51,12 @@ func NewGenerator(logger *zap.Logger) (*Generator, error) {
 		return nil, errors.New("no delimiters found in the file")
 	}
 
-	return &Generator{knownDelimiters: delimiters}, nil
+	provenance := make(map[string][]KnownDelimiter, len(delimiters))
+	for _, delimiter := range delimiters {
+		provenance[delimiter] = append(provenance[delimiter], KnownDelimiter{Token: delimiter, Source: knownDelimitersFilePath})
+	}
+
+	return &Generator{knownDelimiters: delimiters, provenance: provenance, logger: logger}, nil
 }
 
 func (g *Generator) GetKnownDelimiters() []string {
@@ -62,57 +66,193 @@ func (g *Generator) GetKnownDelimiters() []string {
 type Item struct {
 	Type  ItemType
 	Token string
+
+	// Delimiter is the delimiter mentioned by a higher-order expression
+	Delimiter string
+	// Depth is the quotation depth of the mentioned delimiter (0 for a delimiter used as such)
+	Depth int
+	// Rendered is the form of the token in the message when the container format escaped it
+	// (e.g. the quotes of a JSON string), empty when the token is inserted verbatim
+	Rendered string
+}
+
+// Form returns the token as it appears in the message
+func (i Item) Form() string {
+	if i.Rendered != "" {
+		return i.Rendered
+	}
+	return i.Token
 }
 
 type ItemType string
 
 const (
-	Delimiter  ItemType = "delimiter"
-	Expression ItemType = "expression"
+	Delimiter   ItemType = "delimiter"
+	Expression  ItemType = "expression"
+	HigherOrder ItemType = "higher_order"
 )
 
 // TODO: find an alternative name to `Items`
 type Candidate struct {
 	Message string
 	Items   []Item
+	// Format is the container in which the items are embedded
+	Format Format
+	// History is the conversation sent before the message, oldest turn first
+	History []Turn
+	// Canary is the word the model outputs if it obeys the instruction of a forged role header
+	Canary string
+	// Probe is the chat template family of the forged role header
+	Probe string
 }
 
-func (g *Generator) GenerateCandidate(minItemsCount, maxItemsCount int) Candidate {
+// Generate composes the next candidate according to the policy: either a role probe
+// or a candidate preceded by a conversation history
+func (g *Generator) Generate(policy GenerationPolicy) Candidate {
+	if policy.RoleProbeRatio > 0 && random.Float64() < policy.RoleProbeRatio {
+		return g.GenerateRoleProbe()
+	}
+	return g.GenerateConversation(policy)
+}
+
+// GenerateCandidate composes a candidate message made of delimiters and expressions according to the policy
+func (g *Generator) GenerateCandidate(policy GenerationPolicy) Candidate {
 	if len(g.knownDelimiters) == 0 {
 		g.logger.Error("no known delimiters available")
 		return Candidate{}
 	}
 
-	var items []Item
+	if err := policy.Validate(); err != nil {
+		g.logger.Error("invalid generation policy", zap.Error(err))
+		return Candidate{}
+	}
 
-	totalItems := rand.Intn(maxItemsCount) + minItemsCount
-	hasExpression := false
+	kinds := g.layoutItems(policy)
 
-	for i := 0; i < totalItems; i++ {
-		if rand.Intn(5) < 1 && len(g.knownDelimiters) > 0 {
-			delimiter := g.knownDelimiters[rand.Intn(len(g.knownDelimiters))]
-			items = append(items, Item{Type: "delimiter", Token: delimiter})
+	items := make([]Item, 0, len(kinds))
+	for _, kind := range kinds {
+		if kind == Delimiter {
+			delimiter := g.knownDelimiters[random.Intn(len(g.knownDelimiters))]
+			items = append(items, Item{Type: Delimiter, Token: delimiter})
+		} else if random.Float64() < policy.HigherOrderRatio {
+			delimiter := g.knownDelimiters[random.Intn(len(g.knownDelimiters))]
+			items = append(items, GenerateHigherOrderExpression(delimiter))
 		} else {
-			word := gofakeit.Word()
-			items = append(items, Item{Type: "expression", Token: word})
-			hasExpression = true
+			items = append(items, Item{Type: Expression, Token: policy.expression()})
 		}
 	}
 
-	// ensure at least one expression exists
-	if !hasExpression {
-		word := gofakeit.Word()
-		items = append(items, Item{Type: "expression", Token: word})
+	format := policy.format()
+	if format != FormatPlain {
+		message, rendered := renderDocument(format, items)
+		return Candidate{
+			Message: message,
+			Items:   rendered,
+			Format:  format,
+		}
+	}
+
+	var message strings.Builder
+	for i, item := range items {
+		if i > 0 {
+			joiner := policy.Joiners[random.Intn(len(policy.Joiners))]
+			message.WriteString(joinerSeparators[joiner])
+		}
+		message.WriteString(item.Token)
 	}
 
 	return Candidate{
-		Message: strings.Join(func() []string {
-			tokens := make([]string, 0, len(items))
-			for _, item := range items {
-				tokens = append(tokens, item.Token)
-			}
-			return tokens
-		}(), " "),
-		Items: items,
+		Message: message.String(),
+		Items:   items,
+		Format:  FormatPlain,
+	}
+}
+
+// layoutItems decides the type of each item of a candidate, enforcing the position constraints of the policy
+func (g *Generator) layoutItems(policy GenerationPolicy) []ItemType {
+	totalItems := random.Intn(policy.MaxItems-policy.MinItems+1) + policy.MinItems
+
+	var middle bool
+	if policy.BalancedPositions {
+		switch Positions[random.Intn(len(Positions))] {
+		case PositionFirst:
+			policy.DelimiterFirst = true
+		case PositionMiddle:
+			middle = true
+		case PositionLast:
+			policy.DelimiterLast = true
+		case PositionAdjacent:
+			policy.AdjacentDelimiters = true
+		}
+	}
+
+	// make room for the constrained delimiters and at least one expression
+	required := 1
+	if middle {
+		required = 3
 	}
+	if policy.DelimiterFirst {
+		required++
+	}
+	if policy.DelimiterLast {
+		required++
+	}
+	if policy.AdjacentDelimiters {
+		required += 2
+	}
+	if totalItems < required {
+		totalItems = required
+	}
+
+	kinds := make([]ItemType, totalItems)
+	pinned := make([]bool, totalItems)
+	for i := range kinds {
+		if random.Float64() < policy.DelimiterRatio {
+			kinds[i] = Delimiter
+		} else {
+			kinds[i] = Expression
+		}
+	}
+
+	if policy.DelimiterFirst {
+		kinds[0], pinned[0] = Delimiter, true
+	}
+	if policy.DelimiterLast {
+		kinds[totalItems-1], pinned[totalItems-1] = Delimiter, true
+	}
+
+	// unpinned items form a contiguous range [lo, hi] of at least three items when adjacency is requested
+	lo, hi := 0, totalItems-1
+	if policy.DelimiterFirst {
+		lo++
+	}
+	if policy.DelimiterLast {
+		hi--
+	}
+	if policy.AdjacentDelimiters {
+		start := lo + random.Intn(hi-lo)
+		kinds[start], pinned[start] = Delimiter, true
+		kinds[start+1], pinned[start+1] = Delimiter, true
+	}
+
+	if middle {
+		i := 1 + random.Intn(totalItems-2)
+		kinds[i], pinned[i] = Delimiter, true
+		kinds[i-1], pinned[i-1] = Expression, true
+		kinds[i+1], pinned[i+1] = Expression, true
+	}
+
+	// ensure at least one expression exists
+	var free []int
+	for i, kind := range kinds {
+		if kind == Expression {
+			return kinds
+		}
+		if !pinned[i] {
+			free = append(free, i)
+		}
+	}
+	kinds[free[random.Intn(len(free))]] = Expression
+
+	return kinds
 }
@@ -125,8 +125,7 @@ func TestGenerateCandidate(t *testing.T) {
 	tests := []struct {
 		name        string
 		generator   *Generator
-		minItems    int
-		maxItems    int
+		policy      GenerationPolicy
 		checkOutput func(candidate Candidate) error
 	}{
 		{
@@ -135,8 +134,7 @@ func TestGenerateCandidate(t *testing.T) {
 				knownDelimiters: []string{"<|begin_of_text|>", "<|end_of_text|>"},
 				logger:          logger,
 			},
-			minItems: 2,
-			maxItems: 5,
+			policy: DefaultGenerationPolicy(),
 			checkOutput: func(candidate Candidate) error {
 				if len(candidate.Items) < 2 {
 					return errors.New("candidate has fewer items than minimum")
@@ -161,8 +159,7 @@ func TestGenerateCandidate(t *testing.T) {
 			generator: &Generator{
 				logger: logger,
 			},
-			minItems: 2,
-			maxItems: 5,
+			policy: DefaultGenerationPolicy(),
 			checkOutput: func(candidate Candidate) error {
 				if len(candidate.Items) > 0 {
 					return errors.New("expected no candidate items but got some")
@@ -177,10 +174,33 @@ func TestGenerateCandidate(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			got := tc.generator.GenerateCandidate(tc.minItems, tc.maxItems)
+			got := tc.generator.GenerateCandidate(tc.policy)
 			if err := tc.checkOutput(got); err != nil {
 				t.Errorf("Test failed: %v", err)
 			}
 		})
 	}
 }
+
+func TestSeed(t *testing.T) {
+	g := &Generator{
+		knownDelimiters: []string{"<|begin_of_text|>", "<|end_of_text|>", "[INST]"},
+		logger:          zap.NewNop(),
+	}
+
+	generate := func() []string {
+		Seed(42)
+		var messages []string
+		for i := 0; i < 10; i++ {
+			messages = append(messages, g.GenerateCandidate(DefaultGenerationPolicy()).Message)
+		}
+		return messages
+	}
+
+	first, second := generate(), generate()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("expected identical candidates for the same seed, got %q and %q", first[i], second[i])
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/brianvoe/gofakeit/v7"
+)
+
+// maxNestedDepth is the deepest quotation level produced by nested quotations
+const maxNestedDepth = 3
+
+// HigherOrderStyle names the way a higher-order expression mentions a delimiter
+type HigherOrderStyle string
+
+const (
+	StyleSentence  HigherOrderStyle = "sentence"
+	StyleNested    HigherOrderStyle = "nested"
+	StyleCodeBlock HigherOrderStyle = "code_block"
+	StyleJSON      HigherOrderStyle = "json"
+)
+
+var higherOrderStyles = []HigherOrderStyle{StyleSentence, StyleNested, StyleCodeBlock, StyleJSON}
+
+// predicates describe what a delimiter does, to build sentences mentioning it
+var predicates = []string{
+	"ends a turn",
+	"starts a new message",
+	"is a special token",
+	"marks the end of the text",
+	"separates the system prompt from the user message",
+	"must never be repeated",
+}
+
+// quotes alternate with the quotation depth so that nested quotations stay readable
+var quotes = []string{`"`, "'", "`"}
+
+// GenerateHigherOrderExpression returns an expression mentioning the delimiter (rather than using it),
+// in a randomly chosen style
+func GenerateHigherOrderExpression(delimiter string) Item {
+	return GenerateHigherOrderExpressionWithStyle(delimiter, higherOrderStyles[random.Intn(len(higherOrderStyles))])
+}
+
+// GenerateHigherOrderExpressionWithStyle returns an expression mentioning the delimiter in the given style,
+// tagged with the quotation depth of the delimiter
+func GenerateHigherOrderExpressionWithStyle(delimiter string, style HigherOrderStyle) Item {
+	var token string
+	depth := 1
+
+	switch style {
+	case StyleNested:
+		depth = random.Intn(maxNestedDepth-1) + 2
+		token = nestedQuotation(delimiter, depth)
+	case StyleCodeBlock:
+		token = fmt.Sprintf("```\n%s\n```", delimiter)
+	case StyleJSON:
+		token = jsonQuotation(delimiter)
+	default:
+		token = quotedSentence(delimiter, quotes[0])
+	}
+
+	return Item{
+		Type:      HigherOrder,
+		Token:     token,
+		Delimiter: delimiter,
+		Depth:     depth,
+	}
+}
+
+func quotedSentence(delimiter, quote string) string {
+	return fmt.Sprintf("the token %s%s%s %s", quote, delimiter, quote, predicates[random.Intn(len(predicates))])
+}
+
+// nestedQuotation reports a quoted sentence through successive speakers, e.g.
+// Alice wrote "Bob wrote 'the token `<|eot_id|>` ends a turn'"
+func nestedQuotation(delimiter string, depth int) string {
+	text := quotedSentence(delimiter, quotes[(depth-1)%len(quotes)])
+	for level := depth - 1; level >= 1; level-- {
+		quote := quotes[(level-1)%len(quotes)]
+		text = fmt.Sprintf("%s wrote %s%s%s", gofakeit.FirstName(), quote, text, quote)
+	}
+	return text
+}
+
+func jsonQuotation(delimiter string) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	// keep `<`, `>` and `&` as is: escaping them would hide the delimiter
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(map[string]string{"token": delimiter}); err != nil {
+		return fmt.Sprintf(`{"token": %q}`, delimiter)
+	}
+	return strings.TrimSpace(buf.String())
+}
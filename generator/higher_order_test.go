@@ -0,0 +1,90 @@
+package generator
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestGenerateHigherOrderExpressionWithStyle(t *testing.T) {
+	delimiter := "<|eot_id|>"
+
+	tests := []struct {
+		name     string
+		style    HigherOrderStyle
+		minDepth int
+		maxDepth int
+		check    func(token string) bool
+	}{
+		{
+			name:     "sentence",
+			style:    StyleSentence,
+			minDepth: 1,
+			maxDepth: 1,
+			check: func(token string) bool {
+				return strings.HasPrefix(token, `the token "`+delimiter+`"`)
+			},
+		},
+		{
+			name:     "nested quotation",
+			style:    StyleNested,
+			minDepth: 2,
+			maxDepth: maxNestedDepth,
+			check: func(token string) bool {
+				return strings.Contains(token, " wrote ")
+			},
+		},
+		{
+			name:     "code block",
+			style:    StyleCodeBlock,
+			minDepth: 1,
+			maxDepth: 1,
+			check: func(token string) bool {
+				return token == "```\n"+delimiter+"\n```"
+			},
+		},
+		{
+			name:     "json",
+			style:    StyleJSON,
+			minDepth: 1,
+			maxDepth: 1,
+			check: func(token string) bool {
+				var value map[string]string
+				return json.Unmarshal([]byte(token), &value) == nil && value["token"] == delimiter
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for run := 0; run < 50; run++ {
+				item := GenerateHigherOrderExpressionWithStyle(delimiter, tc.style)
+				if item.Type != HigherOrder {
+					t.Fatalf("expected type %s, got %s", HigherOrder, item.Type)
+				}
+				if item.Delimiter != delimiter {
+					t.Fatalf("expected delimiter %s, got %s", delimiter, item.Delimiter)
+				}
+				if item.Depth < tc.minDepth || item.Depth > tc.maxDepth {
+					t.Fatalf("expected depth between %d and %d, got %d", tc.minDepth, tc.maxDepth, item.Depth)
+				}
+				if !strings.Contains(item.Token, delimiter) {
+					t.Fatalf("expected token to contain the delimiter verbatim: %s", item.Token)
+				}
+				if !tc.check(item.Token) {
+					t.Fatalf("unexpected token: %s", item.Token)
+				}
+			}
+		})
+	}
+}
+
+func TestNestedQuotationDepth(t *testing.T) {
+	token := nestedQuotation("[INST]", 3)
+	if strings.Count(token, " wrote ") != 2 {
+		t.Errorf("expected two reporting speakers, got: %s", token)
+	}
+	if !strings.Contains(token, "`[INST]`") {
+		t.Errorf("expected the innermost quotation to use backticks, got: %s", token)
+	}
+}
@@ -0,0 +1,110 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// KnownDelimiter is a delimiter the generator can insert in candidates, along with its provenance
+type KnownDelimiter struct {
+	Token string
+	// Role is the role of the token in its source (bos, eos, special, ...), if known
+	Role string
+	// Source is the file the delimiter was read from
+	Source string
+	// Model is the model the delimiter belongs to, if specific to a model
+	Model string
+	// Family is the model family of the delimiter, when read from a catalog
+	Family string
+	// URL documents the delimiter, if known
+	URL string
+}
+
+// NewGeneratorFromDelimiters creates a generator starting from the given delimiters
+// (e.g. imported from the tokenizer files of a model) rather than from the known delimiters file
+func NewGeneratorFromDelimiters(logger *zap.Logger, delimiters []KnownDelimiter) (*Generator, error) {
+	g := &Generator{logger: logger, provenance: make(map[string][]KnownDelimiter)}
+	if g.AddDelimiters(delimiters) == 0 {
+		return nil, errors.New("no delimiters provided")
+	}
+	return g, nil
+}
+
+// AddDelimiters merges the delimiters into the known delimiters, recording their provenance.
+// It returns the number of delimiters that were not known yet.
+func (g *Generator) AddDelimiters(delimiters []KnownDelimiter) int {
+	if g.provenance == nil {
+		g.provenance = make(map[string][]KnownDelimiter)
+	}
+
+	known := make(map[string]struct{}, len(g.knownDelimiters))
+	for _, token := range g.knownDelimiters {
+		known[token] = struct{}{}
+	}
+
+	added := 0
+	for _, delimiter := range delimiters {
+		if delimiter.Token == "" {
+			continue
+		}
+		if _, ok := known[delimiter.Token]; !ok {
+			known[delimiter.Token] = struct{}{}
+			g.knownDelimiters = append(g.knownDelimiters, delimiter.Token)
+			added++
+		}
+		g.provenance[delimiter.Token] = append(g.provenance[delimiter.Token], delimiter)
+	}
+
+	return added
+}
+
+// Provenance returns where the delimiter comes from: one entry per source it was found in
+func (g *Generator) Provenance(token string) []KnownDelimiter {
+	return append([]KnownDelimiter{}, g.provenance[token]...)
+}
+
+// ReportProvenance writes where each of the delimiters comes from (tokenizer file, catalog family,
+// known delimiters file...), one entry per source
+func (g *Generator) ReportProvenance(w io.Writer, delimiters []string) error {
+	var builder strings.Builder
+	for _, delimiter := range delimiters {
+		builder.WriteString(delimiter + "\n")
+		sources := g.Provenance(delimiter)
+		if len(sources) == 0 {
+			builder.WriteString("  unknown source\n")
+		}
+		for _, source := range sources {
+			builder.WriteString("  " + source.Description() + "\n")
+		}
+	}
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// Description describes the source of the delimiter, e.g. "eos of llama-3 (tokenizer_config.json)"
+func (d KnownDelimiter) Description() string {
+	var description string
+	switch {
+	case d.Role != "" && d.Model != "":
+		description = fmt.Sprintf("%s of %s", d.Role, d.Model)
+	case d.Role != "":
+		description = d.Role
+	case d.Family != "":
+		description = fmt.Sprintf("%s family", d.Family)
+	default:
+		description = "known delimiter"
+	}
+
+	if d.Source != "" {
+		description += fmt.Sprintf(" (%s)", d.Source)
+	}
+	if d.URL != "" {
+		description += " " + d.URL
+	}
+	return description
+}
@@ -0,0 +1,66 @@
+package generator
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestAddDelimiters(t *testing.T) {
+	g := &Generator{knownDelimiters: []string{"<s>"}}
+
+	added := g.AddDelimiters([]KnownDelimiter{
+		{Token: "<s>", Role: "bos", Source: "tokenizer_config.json", Model: "model"},
+		{Token: "<|im_end|>", Role: "special", Source: "tokenizer.json", Model: "model"},
+		{Token: "<|im_end|>", Role: "eos", Source: "special_tokens_map.json", Model: "model"},
+		{Token: ""},
+	})
+
+	if added != 1 {
+		t.Errorf("expected 1 delimiter added, got %d", added)
+	}
+	if expected := []string{"<s>", "<|im_end|>"}; !reflect.DeepEqual(g.knownDelimiters, expected) {
+		t.Errorf("expected %q, got %q", expected, g.knownDelimiters)
+	}
+	if provenance := g.Provenance("<|im_end|>"); len(provenance) != 2 {
+		t.Errorf("expected 2 sources for <|im_end|>, got %d", len(provenance))
+	}
+	if provenance := g.Provenance("<s>"); len(provenance) != 1 || provenance[0].Role != "bos" {
+		t.Errorf("expected the bos provenance of <s>, got %+v", provenance)
+	}
+}
+
+func TestNewGeneratorFromDelimiters(t *testing.T) {
+	if _, err := NewGeneratorFromDelimiters(zap.NewNop(), nil); err == nil {
+		t.Errorf("expected an error for an empty list, got none")
+	}
+
+	g, err := NewGeneratorFromDelimiters(zap.NewNop(), []KnownDelimiter{{Token: "<|eot_id|>"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(g.knownDelimiters, []string{"<|eot_id|>"}) {
+		t.Errorf("expected [<|eot_id|>], got %q", g.knownDelimiters)
+	}
+}
+
+func TestReportProvenance(t *testing.T) {
+	g := &Generator{}
+	g.AddDelimiters([]KnownDelimiter{
+		{Token: "<|im_end|>", Role: "eos", Source: "tokenizer_config.json", Model: "qwen"},
+		{Token: "[INST]", Family: "mistral", Source: "catalog.json"},
+	})
+
+	var builder strings.Builder
+	if err := g.ReportProvenance(&builder, []string{"<|im_end|>", "[INST]", "<s>"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "<|im_end|>\n  eos of qwen (tokenizer_config.json)\n" +
+		"[INST]\n  mistral family (catalog.json)\n" +
+		"<s>\n  unknown source\n"
+	if builder.String() != expected {
+		t.Errorf("expected %q, got %q", expected, builder.String())
+	}
+}
@@ -0,0 +1,195 @@
+package generator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/brianvoe/gofakeit/v7"
+)
+
+// Joiner names the separator inserted between two consecutive items of a candidate
+type Joiner string
+
+const (
+	JoinerSpace   Joiner = "space"
+	JoinerNone    Joiner = "none"
+	JoinerNewline Joiner = "newline"
+	JoinerTab     Joiner = "tab"
+)
+
+var joinerSeparators = map[Joiner]string{
+	JoinerSpace:   " ",
+	JoinerNone:    "",
+	JoinerNewline: "\n",
+	JoinerTab:     "\t",
+}
+
+// ExpressionSource names the kind of text used for the expression items of a candidate
+type ExpressionSource string
+
+const (
+	ExpressionWord     ExpressionSource = "word"
+	ExpressionPhrase   ExpressionSource = "phrase"
+	ExpressionSentence ExpressionSource = "sentence"
+)
+
+// GenerationPolicy describes how candidates are composed: how many items they contain,
+// how often an item is a delimiter, how items are joined, and where delimiters must appear
+type GenerationPolicy struct {
+	MinItems         int              `json:"min_items"`
+	MaxItems         int              `json:"max_items"`
+	DelimiterRatio   float64          `json:"delimiter_ratio"`
+	Joiners          []Joiner         `json:"joiners"`
+	ExpressionSource ExpressionSource `json:"expression_source"`
+	HigherOrderRatio float64          `json:"higher_order_ratio"`
+	// Formats lists the containers to pick from (plain text when empty)
+	Formats []Format `json:"formats"`
+
+	// conversation history sent before the candidate (see GenerateConversation)
+	Turns         int           `json:"turns"`
+	TurnPlacement TurnPlacement `json:"turn_placement"`
+
+	// RoleProbeRatio is the probability for a candidate to be a role probe (see GenerateRoleProbe)
+	RoleProbeRatio float64 `json:"role_probe_ratio"`
+
+	// position constraints
+	DelimiterFirst     bool `json:"delimiter_first"`
+	DelimiterLast      bool `json:"delimiter_last"`
+	AdjacentDelimiters bool `json:"adjacent_delimiters"`
+	// BalancedPositions forces, in each candidate, a delimiter at a position drawn uniformly
+	// among first, middle, last and adjacent, so that every position gets enough samples
+	BalancedPositions bool `json:"balanced_positions"`
+}
+
+// DefaultGenerationPolicy returns the policy historically used by deLLMiter:
+// 2 to 5 single-word items, one in five being a delimiter, joined by spaces
+func DefaultGenerationPolicy() GenerationPolicy {
+	return GenerationPolicy{
+		MinItems:         2,
+		MaxItems:         5,
+		DelimiterRatio:   0.2,
+		Joiners:          []Joiner{JoinerSpace},
+		ExpressionSource: ExpressionWord,
+		Formats:          []Format{FormatPlain},
+		TurnPlacement:    PlaceInUser,
+	}
+}
+
+// LoadGenerationPolicy reads a JSON policy file. Fields absent from the file keep their default values.
+func LoadGenerationPolicy(filePath string) (GenerationPolicy, error) {
+	policy := DefaultGenerationPolicy()
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return policy, fmt.Errorf("failed to read the policy file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return policy, fmt.Errorf("failed to unmarshal the policy file: %w", err)
+	}
+
+	return policy, policy.Validate()
+}
+
+// ParseJoiners converts a comma-separated list of joiner names (e.g. "space,newline") into joiners
+func ParseJoiners(list string) ([]Joiner, error) {
+	var joiners []Joiner
+	for _, name := range strings.Split(list, ",") {
+		joiner := Joiner(strings.TrimSpace(strings.ToLower(name)))
+		if _, ok := joinerSeparators[joiner]; !ok {
+			return nil, fmt.Errorf("unknown joiner: %q", name)
+		}
+		joiners = append(joiners, joiner)
+	}
+	return joiners, nil
+}
+
+// Validate checks that the policy can produce candidates
+func (p GenerationPolicy) Validate() error {
+	if p.MinItems < 1 {
+		return errors.New("minimum items count must be at least 1")
+	}
+	if p.MaxItems < p.MinItems {
+		return fmt.Errorf("maximum items count (%d) is lower than minimum items count (%d)", p.MaxItems, p.MinItems)
+	}
+	if p.DelimiterRatio < 0 || p.DelimiterRatio > 1 {
+		return fmt.Errorf("delimiter ratio must be between 0 and 1, got %v", p.DelimiterRatio)
+	}
+	if p.HigherOrderRatio < 0 || p.HigherOrderRatio > 1 {
+		return fmt.Errorf("higher-order ratio must be between 0 and 1, got %v", p.HigherOrderRatio)
+	}
+	if p.RoleProbeRatio < 0 || p.RoleProbeRatio > 1 {
+		return fmt.Errorf("role probe ratio must be between 0 and 1, got %v", p.RoleProbeRatio)
+	}
+	if len(p.Joiners) == 0 {
+		return errors.New("at least one joiner is required")
+	}
+	for _, joiner := range p.Joiners {
+		if _, ok := joinerSeparators[joiner]; !ok {
+			return fmt.Errorf("unknown joiner: %q", joiner)
+		}
+	}
+	for _, format := range p.Formats {
+		if !format.isValid() {
+			return fmt.Errorf("unknown format: %q", format)
+		}
+	}
+	switch p.ExpressionSource {
+	case ExpressionWord, ExpressionPhrase, ExpressionSentence:
+	default:
+		return fmt.Errorf("unknown expression source: %q", p.ExpressionSource)
+	}
+	if p.Turns < 0 {
+		return fmt.Errorf("turns count must be positive, got %d", p.Turns)
+	}
+	if p.Turns > 0 && p.TurnPlacement != "" {
+		if _, err := ParseTurnPlacement(string(p.TurnPlacement)); err != nil {
+			return err
+		}
+	}
+
+	// the constraints below require room for the mandatory delimiters plus one expression
+	required := 1
+	if p.DelimiterFirst {
+		required++
+	}
+	if p.DelimiterLast {
+		required++
+	}
+	if p.AdjacentDelimiters {
+		required += 2
+	}
+	if p.BalancedPositions {
+		if p.DelimiterFirst || p.DelimiterLast || p.AdjacentDelimiters {
+			return errors.New("balanced positions cannot be combined with other position constraints")
+		}
+		// a middle delimiter between two expressions, or two adjacent delimiters and an expression
+		required = 3
+	}
+	if p.MaxItems < required {
+		return fmt.Errorf("maximum items count (%d) is too low for the position constraints (%d required)", p.MaxItems, required)
+	}
+
+	return nil
+}
+
+func (p GenerationPolicy) expression() string {
+	switch p.ExpressionSource {
+	case ExpressionPhrase:
+		return gofakeit.Phrase()
+	case ExpressionSentence:
+		return gofakeit.Sentence(5)
+	default:
+		return gofakeit.Word()
+	}
+}
+
+func (p GenerationPolicy) format() Format {
+	if len(p.Formats) == 0 {
+		return FormatPlain
+	}
+	return p.Formats[random.Intn(len(p.Formats))]
+}
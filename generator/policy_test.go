@@ -0,0 +1,212 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestGenerationPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		mutate      func(p *GenerationPolicy)
+		expectError bool
+	}{
+		{
+			name:   "default policy",
+			mutate: func(p *GenerationPolicy) {},
+		},
+		{
+			name:        "max lower than min",
+			mutate:      func(p *GenerationPolicy) { p.MinItems, p.MaxItems = 4, 3 },
+			expectError: true,
+		},
+		{
+			name:        "ratio out of range",
+			mutate:      func(p *GenerationPolicy) { p.DelimiterRatio = 1.5 },
+			expectError: true,
+		},
+		{
+			name:        "no joiner",
+			mutate:      func(p *GenerationPolicy) { p.Joiners = nil },
+			expectError: true,
+		},
+		{
+			name:        "unknown expression source",
+			mutate:      func(p *GenerationPolicy) { p.ExpressionSource = "poem" },
+			expectError: true,
+		},
+		{
+			name: "too few items for constraints",
+			mutate: func(p *GenerationPolicy) {
+				p.MaxItems = 4
+				p.DelimiterFirst, p.DelimiterLast, p.AdjacentDelimiters = true, true, true
+			},
+			expectError: true,
+		},
+		{
+			name: "balanced positions with another constraint",
+			mutate: func(p *GenerationPolicy) {
+				p.BalancedPositions, p.DelimiterLast = true, true
+			},
+			expectError: true,
+		},
+		{
+			name: "too few items for balanced positions",
+			mutate: func(p *GenerationPolicy) {
+				p.MinItems, p.MaxItems, p.BalancedPositions = 2, 2, true
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			policy := DefaultGenerationPolicy()
+			tc.mutate(&policy)
+			err := policy.Validate()
+			if tc.expectError && err == nil {
+				t.Errorf("expected an error but got none")
+			}
+			if !tc.expectError && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseJoiners(t *testing.T) {
+	joiners, err := ParseJoiners("space, NEWLINE,tab,none")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []Joiner{JoinerSpace, JoinerNewline, JoinerTab, JoinerNone}
+	if len(joiners) != len(want) {
+		t.Fatalf("expected %v, got %v", want, joiners)
+	}
+	for i := range want {
+		if joiners[i] != want[i] {
+			t.Errorf("expected %v at index %d, got %v", want[i], i, joiners[i])
+		}
+	}
+
+	if _, err := ParseJoiners("space,comma"); err == nil {
+		t.Errorf("expected an error for an unknown joiner")
+	}
+}
+
+func TestLoadGenerationPolicy(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "policy.json")
+	content := `{"min_items": 3, "max_items": 6, "joiners": ["newline"], "delimiter_last": true}`
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write policy file: %v", err)
+	}
+
+	policy, err := LoadGenerationPolicy(filePath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if policy.MinItems != 3 || policy.MaxItems != 6 || !policy.DelimiterLast {
+		t.Errorf("policy fields not loaded: %+v", policy)
+	}
+	if policy.DelimiterRatio != DefaultGenerationPolicy().DelimiterRatio {
+		t.Errorf("expected default delimiter ratio, got %v", policy.DelimiterRatio)
+	}
+}
+
+func TestGenerateCandidateRespectsPolicy(t *testing.T) {
+	g := &Generator{
+		knownDelimiters: []string{"<|begin_of_text|>", "<|eot_id|>", "[INST]"},
+		logger:          zap.NewNop(),
+	}
+
+	tests := []struct {
+		name   string
+		policy GenerationPolicy
+	}{
+		{
+			name:   "default policy",
+			policy: DefaultGenerationPolicy(),
+		},
+		{
+			name: "delimiter first and last joined by newlines",
+			policy: GenerationPolicy{
+				MinItems:         3,
+				MaxItems:         6,
+				DelimiterRatio:   0.5,
+				Joiners:          []Joiner{JoinerNewline},
+				ExpressionSource: ExpressionWord,
+				DelimiterFirst:   true,
+				DelimiterLast:    true,
+			},
+		},
+		{
+			name: "adjacent delimiters without joiner",
+			policy: GenerationPolicy{
+				MinItems:           1,
+				MaxItems:           4,
+				DelimiterRatio:     0,
+				Joiners:            []Joiner{JoinerNone},
+				ExpressionSource:   ExpressionPhrase,
+				AdjacentDelimiters: true,
+			},
+		},
+		{
+			name: "only delimiters requested",
+			policy: GenerationPolicy{
+				MinItems:         2,
+				MaxItems:         2,
+				DelimiterRatio:   1,
+				Joiners:          []Joiner{JoinerTab},
+				ExpressionSource: ExpressionSentence,
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			for run := 0; run < 200; run++ {
+				candidate := g.GenerateCandidate(tc.policy)
+				items := candidate.Items
+
+				if len(items) < tc.policy.MinItems || len(items) > tc.policy.MaxItems {
+					t.Fatalf("expected between %d and %d items, got %d", tc.policy.MinItems, tc.policy.MaxItems, len(items))
+				}
+
+				hasExpression, hasAdjacent := false, false
+				for i, item := range items {
+					if item.Type == Expression {
+						hasExpression = true
+					}
+					if i > 0 && item.Type == Delimiter && items[i-1].Type == Delimiter {
+						hasAdjacent = true
+					}
+				}
+				if !hasExpression {
+					t.Fatalf("candidate contains no expression: %+v", items)
+				}
+				if tc.policy.DelimiterFirst && items[0].Type != Delimiter {
+					t.Fatalf("expected a delimiter first: %+v", items)
+				}
+				if tc.policy.DelimiterLast && items[len(items)-1].Type != Delimiter {
+					t.Fatalf("expected a delimiter last: %+v", items)
+				}
+				if tc.policy.AdjacentDelimiters && !hasAdjacent {
+					t.Fatalf("expected adjacent delimiters: %+v", items)
+				}
+
+				separator := joinerSeparators[tc.policy.Joiners[0]]
+				tokens := make([]string, 0, len(items))
+				for _, item := range items {
+					tokens = append(tokens, item.Token)
+				}
+				if want := strings.Join(tokens, separator); candidate.Message != want {
+					t.Fatalf("expected message %q, got %q", want, candidate.Message)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package generator
+
+// Position is the position of a delimiter among the items of a candidate
+type Position string
+
+const (
+	PositionFirst  Position = "first"
+	PositionMiddle Position = "middle"
+	PositionLast   Position = "last"
+	// PositionAdjacent is orthogonal to the other positions: the delimiter neighbors another delimiter
+	PositionAdjacent Position = "adjacent"
+)
+
+// Positions lists the positions in report order
+var Positions = []Position{PositionFirst, PositionMiddle, PositionLast, PositionAdjacent}
+
+// DelimiterPositions returns the positions of each item of a candidate, nil for the items that are not delimiters.
+// A delimiter is first, middle or last (both first and last when alone), and may also be adjacent.
+// The positions are those of the items, as forced by the generation policy, whatever the format
+// rendering them or the conversation history preceding the message.
+func DelimiterPositions(items []Item) [][]Position {
+	positions := make([][]Position, len(items))
+	for i, item := range items {
+		if item.Type != Delimiter {
+			continue
+		}
+
+		if i == 0 {
+			positions[i] = append(positions[i], PositionFirst)
+		}
+		if i > 0 && i < len(items)-1 {
+			positions[i] = append(positions[i], PositionMiddle)
+		}
+		if i == len(items)-1 {
+			positions[i] = append(positions[i], PositionLast)
+		}
+		if (i > 0 && items[i-1].Type == Delimiter) || (i < len(items)-1 && items[i+1].Type == Delimiter) {
+			positions[i] = append(positions[i], PositionAdjacent)
+		}
+	}
+	return positions
+}
@@ -0,0 +1,77 @@
+package generator
+
+import (
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestDelimiterPositions(t *testing.T) {
+	tests := []struct {
+		name     string
+		items    []Item
+		expected [][]Position
+	}{
+		{
+			name: "first, middle and last",
+			items: []Item{
+				{Type: Delimiter, Token: "<s>"},
+				{Type: Expression, Token: "hello"},
+				{Type: Delimiter, Token: "[INST]"},
+				{Type: Expression, Token: "world"},
+				{Type: Delimiter, Token: "</s>"},
+			},
+			expected: [][]Position{{PositionFirst}, nil, {PositionMiddle}, nil, {PositionLast}},
+		},
+		{
+			name: "adjacent delimiters",
+			items: []Item{
+				{Type: Delimiter, Token: "<s>"},
+				{Type: Delimiter, Token: "[INST]"},
+				{Type: Expression, Token: "hello"},
+			},
+			expected: [][]Position{{PositionFirst, PositionAdjacent}, {PositionMiddle, PositionAdjacent}, nil},
+		},
+		{
+			name:     "lone delimiter",
+			items:    []Item{{Type: Delimiter, Token: "<s>"}},
+			expected: [][]Position{{PositionFirst, PositionLast}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if positions := DelimiterPositions(tc.items); !reflect.DeepEqual(positions, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, positions)
+			}
+		})
+	}
+}
+
+func TestGenerateCandidateBalancedPositions(t *testing.T) {
+	g := &Generator{
+		knownDelimiters: []string{"<|eot_id|>", "[INST]"},
+		logger:          zap.NewNop(),
+	}
+	policy := DefaultGenerationPolicy()
+	policy.MinItems, policy.MaxItems = 3, 5
+	policy.DelimiterRatio = 0
+	policy.BalancedPositions = true
+
+	counts := make(map[Position]int)
+	for run := 0; run < 400; run++ {
+		candidate := g.GenerateCandidate(policy)
+		for _, positions := range DelimiterPositions(candidate.Items) {
+			for _, position := range positions {
+				counts[position]++
+			}
+		}
+	}
+
+	for _, position := range Positions {
+		if counts[position] < 50 {
+			t.Errorf("expected the %s position to be sampled often, got %d samples", position, counts[position])
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package generator
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+)
+
+// random is the source of every random choice made by the generator
+var random = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+// Seed makes the generated candidates reproducible: the same seed yields the same sequence of candidates
+func Seed(seed int64) {
+	random = rand.New(rand.NewSource(seed))
+	gofakeit.GlobalFaker = gofakeit.New(uint64(seed))
+}
@@ -0,0 +1,57 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestGenerateCanary(t *testing.T) {
+	canary, instruction := GenerateCanary()
+	if canary == "" || canary != strings.ToUpper(canary) {
+		t.Fatalf("expected an uppercase canary, got %q", canary)
+	}
+	if strings.Contains(instruction, canary) {
+		t.Errorf("the instruction must not reveal the canary: %s", instruction)
+	}
+}
+
+func TestGenerateRoleProbe(t *testing.T) {
+	g := &Generator{
+		knownDelimiters: []string{"<|eot_id|>", "[INST]"},
+		logger:          zap.NewNop(),
+	}
+
+	families := make(map[string]bool)
+	for run := 0; run < 200; run++ {
+		candidate := g.GenerateRoleProbe()
+		families[candidate.Probe] = true
+
+		if candidate.Canary == "" {
+			t.Fatalf("expected a canary")
+		}
+		if strings.Contains(candidate.Message, candidate.Canary) {
+			t.Fatalf("the message must not contain the canary: %s", candidate.Message)
+		}
+
+		tokens := make([]string, 0, len(candidate.Items))
+		hasDelimiter := false
+		for _, item := range candidate.Items {
+			tokens = append(tokens, item.Token)
+			if item.Type == Delimiter {
+				hasDelimiter = true
+			}
+		}
+		if !hasDelimiter {
+			t.Fatalf("expected a forged header delimiter: %+v", candidate.Items)
+		}
+		if strings.Join(tokens, "") != candidate.Message {
+			t.Fatalf("items do not match the message: %s", candidate.Message)
+		}
+	}
+
+	if !families["generic"] || len(families) < 3 {
+		t.Errorf("expected both template and generic headers, got %v", families)
+	}
+}
@@ -0,0 +1,270 @@
+package gguf
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// magic is "GGUF" read as a little-endian uint32
+const magic = 0x46554747
+
+const (
+	// maxStringLength bounds the strings read from the file, to fail fast on corrupted files
+	maxStringLength = 16 << 20
+	// maxArrayLength bounds the arrays read from the file, for the same reason
+	maxArrayLength = 16 << 20
+)
+
+// ValueType is the type of a metadata value, as encoded in the file
+type ValueType uint32
+
+const (
+	TypeUint8 ValueType = iota
+	TypeInt8
+	TypeUint16
+	TypeInt16
+	TypeUint32
+	TypeInt32
+	TypeFloat32
+	TypeBool
+	TypeString
+	TypeArray
+	TypeUint64
+	TypeInt64
+	TypeFloat64
+)
+
+// Metadata holds the key-value pairs of the header of a GGUF file
+type Metadata struct {
+	Version     uint32
+	TensorCount uint64
+	Values      map[string]any
+}
+
+// ReadFile reads the metadata of a GGUF file. Tensors are not read.
+func ReadFile(filePath string) (*Metadata, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the GGUF file: %w", err)
+	}
+	defer file.Close()
+
+	return Read(bufio.NewReader(file))
+}
+
+// Read reads the metadata from the beginning of a GGUF stream. Versions 2 and 3 are supported.
+func Read(r io.Reader) (*Metadata, error) {
+	d := &decoder{r: r}
+
+	if fileMagic := d.uint32(); d.err == nil && fileMagic != magic {
+		return nil, errors.New("not a GGUF file")
+	}
+
+	metadata := &Metadata{Version: d.uint32()}
+	if d.err == nil && metadata.Version < 2 {
+		return nil, fmt.Errorf("unsupported GGUF version: %d", metadata.Version)
+	}
+
+	metadata.TensorCount = d.uint64()
+	count := d.uint64()
+	if d.err != nil {
+		return nil, fmt.Errorf("failed to read the GGUF header: %w", d.err)
+	}
+
+	metadata.Values = make(map[string]any)
+	for i := uint64(0); i < count; i++ {
+		key := d.string()
+		value := d.value(ValueType(d.uint32()))
+		if d.err != nil {
+			return nil, fmt.Errorf("failed to read metadata entry %d: %w", i, d.err)
+		}
+		metadata.Values[key] = value
+	}
+
+	return metadata, nil
+}
+
+// String returns the string value of the key
+func (m *Metadata) String(key string) (string, bool) {
+	value, ok := m.Values[key].(string)
+	return value, ok
+}
+
+// Strings returns the string array value of the key
+func (m *Metadata) Strings(key string) ([]string, bool) {
+	values, ok := m.Values[key].([]any)
+	if !ok {
+		return nil, false
+	}
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		s, ok := value.(string)
+		if !ok {
+			return nil, false
+		}
+		result = append(result, s)
+	}
+	return result, true
+}
+
+// Ints returns the integer array value of the key, whatever the width of its integers
+func (m *Metadata) Ints(key string) ([]int64, bool) {
+	values, ok := m.Values[key].([]any)
+	if !ok {
+		return nil, false
+	}
+	ints := make([]int64, 0, len(values))
+	for _, value := range values {
+		i, ok := toInt(value)
+		if !ok {
+			return nil, false
+		}
+		ints = append(ints, i)
+	}
+	return ints, true
+}
+
+// Int returns the integer value of the key, whatever its width
+func (m *Metadata) Int(key string) (int64, bool) {
+	return toInt(m.Values[key])
+}
+
+func toInt(value any) (int64, bool) {
+	switch v := value.(type) {
+	case uint8:
+		return int64(v), true
+	case int8:
+		return int64(v), true
+	case uint16:
+		return int64(v), true
+	case int16:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case uint64:
+		if v > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v), true
+	case int64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// decoder reads little-endian values, keeping the first error encountered
+type decoder struct {
+	r   io.Reader
+	err error
+}
+
+func (d *decoder) read(data any) {
+	if d.err != nil {
+		return
+	}
+	d.err = binary.Read(d.r, binary.LittleEndian, data)
+}
+
+func (d *decoder) uint32() uint32 {
+	var v uint32
+	d.read(&v)
+	return v
+}
+
+func (d *decoder) uint64() uint64 {
+	var v uint64
+	d.read(&v)
+	return v
+}
+
+func (d *decoder) string() string {
+	length := d.uint64()
+	if d.err != nil {
+		return ""
+	}
+	if length > maxStringLength {
+		d.err = fmt.Errorf("string too long: %d bytes", length)
+		return ""
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(d.r, buf); err != nil {
+		d.err = err
+		return ""
+	}
+	return string(buf)
+}
+
+func (d *decoder) value(valueType ValueType) any {
+	switch valueType {
+	case TypeUint8:
+		var v uint8
+		d.read(&v)
+		return v
+	case TypeInt8:
+		var v int8
+		d.read(&v)
+		return v
+	case TypeUint16:
+		var v uint16
+		d.read(&v)
+		return v
+	case TypeInt16:
+		var v int16
+		d.read(&v)
+		return v
+	case TypeUint32:
+		return d.uint32()
+	case TypeInt32:
+		var v int32
+		d.read(&v)
+		return v
+	case TypeFloat32:
+		var v float32
+		d.read(&v)
+		return v
+	case TypeBool:
+		var v uint8
+		d.read(&v)
+		return v != 0
+	case TypeString:
+		return d.string()
+	case TypeArray:
+		elementType := ValueType(d.uint32())
+		length := d.uint64()
+		if d.err != nil {
+			return nil
+		}
+		if length > maxArrayLength {
+			d.err = fmt.Errorf("array too long: %d elements", length)
+			return nil
+		}
+		values := make([]any, 0, length)
+		for i := uint64(0); i < length && d.err == nil; i++ {
+			values = append(values, d.value(elementType))
+		}
+		return values
+	case TypeUint64:
+		return d.uint64()
+	case TypeInt64:
+		var v int64
+		d.read(&v)
+		return v
+	case TypeFloat64:
+		var v float64
+		d.read(&v)
+		return v
+	default:
+		if d.err == nil {
+			d.err = fmt.Errorf("unknown value type: %d", valueType)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,182 @@
+package gguf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// encoder writes a GGUF header for the tests
+type encoder struct {
+	buf bytes.Buffer
+}
+
+func (e *encoder) write(data any) {
+	_ = binary.Write(&e.buf, binary.LittleEndian, data)
+}
+
+func (e *encoder) string(s string) {
+	e.write(uint64(len(s)))
+	e.buf.WriteString(s)
+}
+
+func (e *encoder) header(version uint32, count uint64) {
+	e.write(uint32(magic))
+	e.write(version)
+	e.write(uint64(0))
+	e.write(count)
+}
+
+func (e *encoder) stringValue(key, value string) {
+	e.string(key)
+	e.write(uint32(TypeString))
+	e.string(value)
+}
+
+func (e *encoder) uint32Value(key string, value uint32) {
+	e.string(key)
+	e.write(uint32(TypeUint32))
+	e.write(value)
+}
+
+func (e *encoder) stringArray(key string, values []string) {
+	e.string(key)
+	e.write(uint32(TypeArray))
+	e.write(uint32(TypeString))
+	e.write(uint64(len(values)))
+	for _, value := range values {
+		e.string(value)
+	}
+}
+
+func (e *encoder) int32Array(key string, values []int32) {
+	e.string(key)
+	e.write(uint32(TypeArray))
+	e.write(uint32(TypeInt32))
+	e.write(uint64(len(values)))
+	for _, value := range values {
+		e.write(value)
+	}
+}
+
+func testFile() []byte {
+	e := &encoder{}
+	e.header(3, 7)
+	e.stringValue("general.architecture", "llama")
+	e.string("general.alignment")
+	e.write(uint32(TypeUint32))
+	e.write(uint32(32))
+	e.string("general.flag")
+	e.write(uint32(TypeBool))
+	e.write(uint8(1))
+	e.stringValue("tokenizer.chat_template", "{% for message in messages %}{{ message.content }}{% endfor %}")
+	e.stringArray("tokenizer.ggml.tokens", []string{"<unk>", "<|begin_of_text|>", "hello", "<|eot_id|>", "<0x0A>", "<think>"})
+	e.int32Array("tokenizer.ggml.token_type", []int32{2, 3, 1, 3, 6, 4})
+	e.uint32Value("tokenizer.ggml.bos_token_id", 1)
+	return e.buf.Bytes()
+}
+
+func TestRead(t *testing.T) {
+	metadata, err := Read(bytes.NewReader(testFile()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if metadata.Version != 3 {
+		t.Errorf("expected version 3, got %d", metadata.Version)
+	}
+	if architecture, ok := metadata.String("general.architecture"); !ok || architecture != "llama" {
+		t.Errorf("unexpected architecture: %v", architecture)
+	}
+	if alignment, ok := metadata.Int("general.alignment"); !ok || alignment != 32 {
+		t.Errorf("unexpected alignment: %v", alignment)
+	}
+	if flag, ok := metadata.Values["general.flag"].(bool); !ok || !flag {
+		t.Errorf("unexpected flag: %v", metadata.Values["general.flag"])
+	}
+	if template, ok := metadata.ChatTemplate(); !ok || !strings.HasPrefix(template, "{% for") {
+		t.Errorf("unexpected chat template: %v", template)
+	}
+}
+
+func TestSpecialTokens(t *testing.T) {
+	metadata, err := Read(bytes.NewReader(testFile()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tokens, err := metadata.SpecialTokens()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []SpecialToken{
+		{ID: 1, Text: "<|begin_of_text|>", Type: TokenControl, Roles: []string{"bos"}},
+		{ID: 3, Text: "<|eot_id|>", Type: TokenControl},
+		{ID: 5, Text: "<think>", Type: TokenUserDefined},
+	}
+	if !reflect.DeepEqual(tokens, want) {
+		t.Errorf("expected %+v, got %+v", want, tokens)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data func() []byte
+	}{
+		{
+			name: "not a GGUF file",
+			data: func() []byte { return []byte("PK\x03\x04 not a model at all") },
+		},
+		{
+			name: "unsupported version",
+			data: func() []byte {
+				e := &encoder{}
+				e.header(1, 0)
+				return e.buf.Bytes()
+			},
+		},
+		{
+			name: "truncated",
+			data: func() []byte {
+				file := testFile()
+				return file[:len(file)-10]
+			},
+		},
+		{
+			name: "unknown value type",
+			data: func() []byte {
+				e := &encoder{}
+				e.header(3, 1)
+				e.string("key")
+				e.write(uint32(99))
+				return e.buf.Bytes()
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Read(bytes.NewReader(tc.data())); err == nil {
+				t.Errorf("expected an error but got none")
+			}
+		})
+	}
+}
+
+func TestSpecialTokensWithoutVocabulary(t *testing.T) {
+	e := &encoder{}
+	e.header(3, 1)
+	e.stringValue("general.architecture", "llama")
+
+	metadata, err := Read(bytes.NewReader(e.buf.Bytes()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := metadata.SpecialTokens(); err == nil {
+		t.Errorf("expected an error for a missing vocabulary")
+	}
+}
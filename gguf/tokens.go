@@ -0,0 +1,99 @@
+package gguf
+
+import (
+	"errors"
+	"fmt"
+)
+
+// TokenType is the type of a vocabulary token (tokenizer.ggml.token_type)
+type TokenType int64
+
+const (
+	TokenNormal      TokenType = 1
+	TokenUnknown     TokenType = 2
+	TokenControl     TokenType = 3
+	TokenUserDefined TokenType = 4
+	TokenUnused      TokenType = 5
+	TokenByte        TokenType = 6
+)
+
+func (t TokenType) String() string {
+	switch t {
+	case TokenNormal:
+		return "normal"
+	case TokenUnknown:
+		return "unknown"
+	case TokenControl:
+		return "control"
+	case TokenUserDefined:
+		return "user_defined"
+	case TokenUnused:
+		return "unused"
+	case TokenByte:
+		return "byte"
+	default:
+		return fmt.Sprintf("type_%d", int64(t))
+	}
+}
+
+// SpecialToken is a control or user-defined token of the vocabulary, or a token with a special role
+type SpecialToken struct {
+	ID   int
+	Text string
+	Type TokenType
+	// Roles lists the special roles of the token (bos, eos, eot, ...), if any
+	Roles []string
+}
+
+// roleKeys maps the metadata keys holding the id of a special token to its role
+var roleKeys = []struct {
+	key  string
+	role string
+}{
+	{"tokenizer.ggml.bos_token_id", "bos"},
+	{"tokenizer.ggml.eos_token_id", "eos"},
+	{"tokenizer.ggml.eot_token_id", "eot"},
+	{"tokenizer.ggml.eom_token_id", "eom"},
+	{"tokenizer.ggml.unknown_token_id", "unk"},
+	{"tokenizer.ggml.padding_token_id", "pad"},
+	{"tokenizer.ggml.separator_token_id", "sep"},
+}
+
+// ChatTemplate returns the Jinja chat template of the model, if any
+func (m *Metadata) ChatTemplate() (string, bool) {
+	return m.String("tokenizer.chat_template")
+}
+
+// SpecialTokens returns the control and user-defined tokens of the vocabulary, and the tokens
+// referenced as bos, eos, etc., in vocabulary order
+func (m *Metadata) SpecialTokens() ([]SpecialToken, error) {
+	tokens, ok := m.Strings("tokenizer.ggml.tokens")
+	if !ok {
+		return nil, errors.New("no vocabulary found in the GGUF metadata")
+	}
+	types, ok := m.Ints("tokenizer.ggml.token_type")
+	if !ok || len(types) != len(tokens) {
+		return nil, errors.New("no valid token types found in the GGUF metadata")
+	}
+
+	roles := make(map[int][]string)
+	for _, roleKey := range roleKeys {
+		if id, ok := m.Int(roleKey.key); ok && id >= 0 && id < int64(len(tokens)) {
+			roles[int(id)] = append(roles[int(id)], roleKey.role)
+		}
+	}
+
+	var special []SpecialToken
+	for id, text := range tokens {
+		tokenType := TokenType(types[id])
+		if text == "" {
+			continue
+		}
+		if tokenType != TokenControl && tokenType != TokenUserDefined && len(roles[id]) == 0 {
+			continue
+		}
+		special = append(special, SpecialToken{ID: id, Text: text, Type: tokenType, Roles: roles[id]})
+	}
+
+	return special, nil
+}
@@ -5,6 +5,7 @@ go 1.23.4
 require (
 	github.com/brianvoe/gofakeit/v7 v7.2.1
 	go.uber.org/zap v1.27.0
+	golang.org/x/text v0.24.0
 )
 
 require go.uber.org/multierr v1.11.0 // indirect
@@ -1,16 +1,25 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/glethuillier/deLLMiter/analyzer"
 	"github.com/glethuillier/deLLMiter/client"
+	"github.com/glethuillier/deLLMiter/experiment"
 	"github.com/glethuillier/deLLMiter/generator"
+	"github.com/glethuillier/deLLMiter/tokenizer"
 	"github.com/glethuillier/deLLMiter/utils"
 
 	"go.uber.org/zap"
@@ -19,8 +28,63 @@ import (
 const defaultAPIURL = "http://localhost:1234"
 
 func main() {
+	subcommands := map[string]func(args []string) error{
+		"extract":  runExtract,
+		"render":   runRender,
+		"evaluate": runEvaluate,
+	}
+	if len(os.Args) > 1 {
+		if subcommand, ok := subcommands[os.Args[1]]; ok {
+			if err := subcommand(os.Args[2:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	modelName := flag.String("model", "", "The name of the model to use (required).")
 	apiURL := flag.String("apiURL", defaultAPIURL, "The API URL to use for querying (optional).")
+	policyFile := flag.String("policy", "", "A JSON file describing the candidate generation policy (optional).")
+	minItems := flag.Int("minItems", 2, "The minimum number of items per candidate (optional).")
+	maxItems := flag.Int("maxItems", 5, "The maximum number of items per candidate (optional).")
+	delimiterRatio := flag.Float64("delimiterRatio", 0.2, "The probability for an item to be a delimiter (optional).")
+	joiners := flag.String("joiners", "space", "Comma-separated joiners between items: space, none, newline, tab (optional).")
+	expressions := flag.String("expressions", "word", "The expression source: word, phrase, sentence (optional).")
+	higherOrderRatio := flag.Float64("higherOrderRatio", 0, "The probability for an expression to quote a delimiter (optional).")
+	formats := flag.String("formats", "plain", "Comma-separated containers: plain, json, xml, html, markdown, csv, yaml, code (optional).")
+	turns := flag.Int("turns", 0, "The number of user/assistant exchanges sent before each candidate (optional).")
+	turnPlacement := flag.String("turnPlacement", "user", "The history turns holding delimiters: user, assistant, both (optional).")
+	roleProbeRatio := flag.Float64("roleProbeRatio", 0, "The probability for a candidate to forge a role header carrying a canary instruction (optional).")
+	strategiesFile := flag.String("strategies", "", "A JSON file describing the prompt strategies per model (optional).")
+	leakReference := flag.String("leakReference", "", "A file holding a custom system prompt whose leakage must be detected (optional).")
+	samplingFile := flag.String("sampling", "", "A JSON file holding the sampling parameters (optional).")
+	temperature := flag.Float64("temperature", 0.8, "The sampling temperature, 0 for greedy decoding (optional).")
+	topP := flag.Float64("topP", 1, "The nucleus sampling probability mass (optional).")
+	topK := flag.Int("topK", 0, "The number of most likely tokens to sample from (optional).")
+	samplingSeed := flag.Int64("samplingSeed", 0, "The seed of the model sampler, for reproducible responses (optional).")
+	maxTokens := flag.Int("maxTokens", 0, "The maximum number of tokens of a response (optional).")
+	repeatPenalty := flag.Float64("repeatPenalty", 1, "The penalty applied to repeated tokens (optional).")
+	var stop stringsFlag
+	flag.Var(&stop, "stop", "A stop sequence; repeat the flag for several sequences (optional).")
+	mode := flag.String("mode", "probe", "The run mode: probe (continuous probing), ablation (echo instruction comparison), sweep (temperature sweep) (optional).")
+	candidatesCount := flag.Int("candidates", 50, "The number of candidates replayed by the ablation and sweep modes (optional).")
+	temperatures := flag.String("temperatures", "0,0.4,0.8,1.2", "Comma-separated temperatures of the sweep mode (optional).")
+	seed := flag.Int64("seed", 0, "The seed of the candidate generator, for reproducible runs (optional).")
+	delimiterFirst := flag.Bool("delimiterFirst", false, "Force a delimiter as the first item (optional).")
+	delimiterLast := flag.Bool("delimiterLast", false, "Force a delimiter as the last item (optional).")
+	adjacentDelimiters := flag.Bool("adjacentDelimiters", false, "Force a pair of adjacent delimiters (optional).")
+	balancedPositions := flag.Bool("balancedPositions", false, "Force a delimiter first, in the middle, last or next to another one, in turn at random (optional).")
+	tokenizerDir := flag.String("tokenizer", "", "A directory holding Hugging Face tokenizer files whose special tokens are used as delimiters (optional).")
+	catalogDir := flag.String("catalog", generator.CatalogDir, "The delimiter catalog directory, holding one file per model family (optional).")
+	families := flag.String("families", "", "Comma-separated delimiter families of the catalog, detected from the model name if not set (optional).")
+	truth := flag.String("truth", "", "The real special tokens of the model (delimiters file, GGUF file or tokenizer directory) to evaluate the verdicts against (optional).")
+	withKnownDelimiters := flag.Bool("withKnownDelimiters", false, "Also use the generic known delimiters along with the tokenizer special tokens (optional).")
+	resume := flag.Bool("resume", false, "Resume the analysis from the state persisted by a previous run of the probe mode (optional).")
+	saveInterval := flag.Duration("saveInterval", time.Minute, "The period at which the analyzer state is persisted, 0 to only save it on shutdown (optional).")
+	enabledDetectors := flag.String("detectors", "", "Comma-separated detectors run on each response, all if not set (optional).")
+	skippedDetectors := flag.String("skipDetectors", "", "Comma-separated detectors not to run (optional).")
+	normalize := flag.String("normalize", "case", "Comma-separated normalizations applied before comparing the candidate and the response: nfc, nfkc, trailing-newline, whitespace, quotes, case, none (optional).")
 	flag.Parse()
 
 	if *modelName == "" {
@@ -40,7 +104,102 @@ func main() {
 		}
 	}()
 
-	gen, err := generator.NewGenerator(logger)
+	policy := generator.DefaultGenerationPolicy()
+	if *policyFile != "" {
+		if policy, err = generator.LoadGenerationPolicy(*policyFile); err != nil {
+			logger.Fatal("Failed to load the generation policy", zap.Error(err))
+		}
+	}
+
+	// flags explicitly set on the command line take precedence over the policy file
+	// the parse errors are gathered, as a later flag must not mask the error of an earlier one
+	var flagErrs []error
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "minItems":
+			policy.MinItems = *minItems
+		case "maxItems":
+			policy.MaxItems = *maxItems
+		case "delimiterRatio":
+			policy.DelimiterRatio = *delimiterRatio
+		case "joiners":
+			var parseErr error
+			policy.Joiners, parseErr = generator.ParseJoiners(*joiners)
+			flagErrs = append(flagErrs, parseErr)
+		case "expressions":
+			policy.ExpressionSource = generator.ExpressionSource(*expressions)
+		case "higherOrderRatio":
+			policy.HigherOrderRatio = *higherOrderRatio
+		case "formats":
+			var parseErr error
+			policy.Formats, parseErr = generator.ParseFormats(*formats)
+			flagErrs = append(flagErrs, parseErr)
+		case "turns":
+			policy.Turns = *turns
+		case "turnPlacement":
+			var parseErr error
+			policy.TurnPlacement, parseErr = generator.ParseTurnPlacement(*turnPlacement)
+			flagErrs = append(flagErrs, parseErr)
+		case "roleProbeRatio":
+			policy.RoleProbeRatio = *roleProbeRatio
+		case "delimiterFirst":
+			policy.DelimiterFirst = *delimiterFirst
+		case "delimiterLast":
+			policy.DelimiterLast = *delimiterLast
+		case "adjacentDelimiters":
+			policy.AdjacentDelimiters = *adjacentDelimiters
+		case "balancedPositions":
+			policy.BalancedPositions = *balancedPositions
+		}
+	})
+	if flagErr := errors.Join(flagErrs...); flagErr != nil {
+		logger.Fatal("Invalid generation flags", zap.Error(flagErr))
+	}
+	if err := policy.Validate(); err != nil {
+		logger.Fatal("Invalid generation policy", zap.Error(err))
+	}
+
+	if *seed != 0 {
+		generator.Seed(*seed)
+	}
+
+	sampling := client.Sampling{}
+	if *samplingFile != "" {
+		if sampling, err = client.LoadSampling(*samplingFile); err != nil {
+			logger.Fatal("Failed to load the sampling parameters", zap.Error(err))
+		}
+	}
+
+	// flags explicitly set on the command line take precedence over the sampling file
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "temperature":
+			sampling.Temperature = temperature
+		case "topP":
+			sampling.TopP = topP
+		case "topK":
+			sampling.TopK = topK
+		case "samplingSeed":
+			sampling.Seed = samplingSeed
+		case "maxTokens":
+			sampling.MaxTokens = maxTokens
+		case "repeatPenalty":
+			sampling.RepeatPenalty = repeatPenalty
+		case "stop":
+			sampling.Stop = stop
+		}
+	})
+	if err := sampling.Validate(); err != nil {
+		logger.Fatal("Invalid sampling parameters", zap.Error(err))
+	}
+
+	gen, err := newGenerator(logger, delimiterSources{
+		tokenizerDir:        *tokenizerDir,
+		withKnownDelimiters: *withKnownDelimiters,
+		catalogDir:          *catalogDir,
+		families:            generator.ParseFamilies(*families),
+		modelName:           *modelName,
+	})
 	if err != nil {
 		logger.Fatal("Failed to create generator", zap.Error(err))
 	}
@@ -50,7 +209,138 @@ func main() {
 		logger.Fatal("Failed to create client", zap.Error(err))
 	}
 
+	strategies := client.DefaultStrategyRegistry()
+	if *strategiesFile != "" {
+		if strategies, err = client.LoadStrategyRegistry(*strategiesFile); err != nil {
+			logger.Fatal("Failed to load the prompt strategies", zap.Error(err))
+		}
+	}
+	cl.UseStrategies(strategies)
+	cl.UseSampling(sampling)
+
+	strategyName, strategy := strategies.Lookup(*modelName)
+	logger.Info("Prompt strategy selected", zap.String("strategy", strategyName),
+		zap.Stringer("sampling", cl.EffectiveSampling(strategy, *modelName)))
+
+	var truthTokens []string
+	if *truth != "" {
+		if truthTokens, err = loadGroundTruth(*truth); err != nil {
+			logger.Fatal("Failed to load the ground truth", zap.Error(err))
+		}
+	}
+
+	detectors, err := analyzer.ParseDetectors(*enabledDetectors, *skippedDetectors)
+	if err != nil {
+		logger.Fatal("Invalid detectors", zap.Error(err))
+	}
+
+	normalizer, err := analyzer.ParseNormalizer(*normalize)
+	if err != nil {
+		logger.Fatal("Invalid normalization", zap.Error(err))
+	}
+
+	switch *mode {
+	case "probe":
+		runProbe(logger, gen, cl, policy, *modelName, strategy, probeOptions{
+			leakReference:   *leakReference,
+			truth:           truthTokens,
+			knownDelimiters: append(gen.GetKnownDelimiters(), catalogDelimiters(*catalogDir)...),
+			resume:          *resume,
+			saveInterval:    *saveInterval,
+			detectors:       detectors,
+			normalizer:      normalizer,
+		})
+	case "ablation":
+		runAblation(logger, gen, cl, policy, *modelName, *candidatesCount, experiment.Analysis{Detectors: detectors, Normalizer: normalizer})
+	case "sweep":
+		sweepTemperatures, parseErr := experiment.ParseTemperatures(*temperatures)
+		if parseErr != nil {
+			logger.Fatal("Invalid temperatures", zap.Error(parseErr))
+		}
+		runSweep(logger, gen, cl, policy, *modelName, *candidatesCount, sampling, sweepTemperatures,
+			experiment.Analysis{Detectors: detectors, Normalizer: normalizer})
+	default:
+		logger.Fatal("Unknown mode", zap.String("mode", *mode))
+	}
+}
+
+// probeOptions are the optional inputs of the probe mode
+type probeOptions struct {
+	// leakReference is a file holding a text whose leakage must be detected
+	leakReference string
+	// truth holds the real special tokens of the model, to evaluate the verdicts of the analyzer
+	truth []string
+	// knownDelimiters are looked for in the responses, to detect the delimiters emitted by the model
+	knownDelimiters []string
+	// resume reloads the analyzer state persisted by a previous run
+	resume bool
+	// saveInterval is the period at which the analyzer state is persisted, 0 to only save it on shutdown
+	saveInterval time.Duration
+	// detectors are the names of the detectors run on each response
+	detectors []string
+	// normalizer is applied to the candidates and to the responses before comparing them
+	normalizer analyzer.Normalizer
+}
+
+// runProbe continuously sends candidates to the model and records the discrepancies, until interrupted
+func runProbe(logger *zap.Logger, gen *generator.Generator, cl *client.Client, policy generator.GenerationPolicy,
+	modelName string, strategy client.PromptStrategy, options probeOptions) {
 	analyzer := analyzer.NewAnalyzer()
+	analyzer.AddKnownDelimiters(options.knownDelimiters)
+	analyzer.UseNormalizer(options.normalizer)
+	for role, instruction := range strategy.Instructions() {
+		analyzer.AddLeakReference(role+" instruction", instruction)
+	}
+	if options.leakReference != "" {
+		reference, readErr := os.ReadFile(options.leakReference)
+		if readErr != nil {
+			logger.Fatal("Failed to read the leak reference", zap.Error(readErr))
+		}
+		analyzer.AddLeakReference(options.leakReference, string(reference))
+	}
+
+	if options.resume {
+		state, loadErr := loadState(modelName)
+		switch {
+		case errors.Is(loadErr, fs.ErrNotExist):
+			logger.Warn("No analyzer state to resume, starting afresh", zap.String("path", utils.StateFilePath(modelName)))
+		case loadErr != nil:
+			logger.Fatal("Failed to load the analyzer state", zap.Error(loadErr))
+		default:
+			if restoreErr := analyzer.Restore(state); restoreErr != nil {
+				logger.Fatal("Failed to restore the analyzer state", zap.Error(restoreErr))
+			}
+			logger.Info("Analyzer state resumed", zap.Time("savedAt", state.SavedAt), zap.Int("delimiters", len(state.Delimiters)))
+		}
+	}
+
+	pipeline, err := analyzer.NewPipeline(options.detectors)
+	if err != nil {
+		logger.Fatal("Failed to create the detector pipeline", zap.Error(err))
+	}
+
+	effectiveSampling := cl.EffectiveSampling(strategy, modelName)
+
+	// mu guards the analyzer, which is saved on shutdown while the main loop may be running
+	var mu sync.Mutex
+	// saveState persists the analyzer state, along with the evaluation of its detections against the ground truth
+	saveState := func() {
+		writeState := func(w io.Writer) error {
+			encoder := json.NewEncoder(w)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(analyzer.State())
+		}
+		if saveStateErr := utils.SaveFile(utils.StateFilePath(modelName), false, writeState); saveStateErr != nil {
+			logger.Error("Failed to save the analyzer state", zap.Error(saveStateErr))
+		}
+		if len(options.truth) > 0 {
+			// the probe has its own file, so that an offline evaluation does not overwrite it
+			evaluation := analyzer.Evaluate(options.truth, gen.GetKnownDelimiters())
+			if saveEvalErr := utils.SaveFile(utils.ResultPath(modelName, "probe_evaluation.txt"), false, evaluation.Report); saveEvalErr != nil {
+				logger.Error("Failed to save the evaluation", zap.Error(saveEvalErr))
+			}
+		}
+	}
 
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
@@ -60,32 +350,325 @@ func main() {
 	go func() {
 		<-stop
 		logger.Info("deLLMiter shutting down.")
+		mu.Lock()
+		saveState()
 		os.Exit(0)
 	}()
 
+	lastSave := time.Now()
 	for {
-		candidate := gen.GenerateCandidate(2, 4)
+		candidate := gen.Generate(policy)
 
-		response, queryErr := cl.Query(*modelName, candidate.Message)
+		response, queryErr := cl.QueryConversation(modelName, historyMessages(candidate), candidate.Message)
 		if queryErr != nil {
 			logger.Error("Failed to query the model", zap.Error(queryErr))
 			continue
 		}
 
-		areIdentical, mismatchedDelimiters := analyzer.AreIdentical(candidate, response)
-		if !areIdentical {
-			fmt.Printf("Send:	 %s\n", candidate.Message)
-			fmt.Printf("Received: %s\n\n", response)
+		mu.Lock()
+		analyzeResponse(logger, analyzer, pipeline, gen, policy, modelName, effectiveSampling, options, candidate, response)
+		if options.saveInterval > 0 && time.Since(lastSave) >= options.saveInterval {
+			saveState()
+			lastSave = time.Now()
+		}
+		mu.Unlock()
+	}
+}
+
+// loadState reads the analyzer state persisted for the model
+func loadState(modelName string) (analyzer.State, error) {
+	fileName := utils.StateFilePath(modelName)
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return analyzer.State{}, fmt.Errorf("failed to read file %s: %w", fileName, err)
+	}
+
+	// tallies absent from the file keep their initialized value
+	state := analyzer.State{Tallies: analyzer.NewAnalyzer().Tallies}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return analyzer.State{}, fmt.Errorf("failed to decode file %s: %w", fileName, err)
+	}
+
+	return state, nil
+}
+
+// analyzeResponse runs the detectors of the pipeline on a response to a candidate and saves their results
+func analyzeResponse(logger *zap.Logger, analyzer *analyzer.Analyzer, pipeline *analyzer.Pipeline, gen *generator.Generator,
+	policy generator.GenerationPolicy, modelName string, effectiveSampling client.Sampling, options probeOptions,
+	candidate generator.Candidate, response string) {
+	result := pipeline.Run(candidate, response)
+
+	for _, report := range pipeline.Reports(result) {
+		if reportErr := utils.SaveFile(utils.ResultPath(modelName, report.Name), report.Append, report.Write); reportErr != nil {
+			logger.Error("Failed to save the report of a detector", zap.String("report", report.Name), zap.Error(reportErr))
+		}
+	}
+
+	if len(result.Findings) > 0 {
+		for _, finding := range result.Findings {
+			fmt.Println(finding)
+		}
+		if saveFindErr := utils.SaveFile(utils.ResultPath(modelName, "findings.txt"), true, result.ReportFindings); saveFindErr != nil {
+			logger.Error("Failed to save the findings", zap.Error(saveFindErr))
+		}
+	}
+
+	// the delimiter analysis only ran on the echo stripped of its wrappers
+	if !result.Compliance.IsCompliant() {
+		fmt.Printf("Non-compliant response (%s): %s\n\n", result.Compliance, response)
+		if saveErr := utils.SaveFile(utils.ResultPath(modelName, "all.txt"), true, reportObservation(result.Observation, effectiveSampling)); saveErr != nil {
+			logger.Error("Failed to save the non-compliant response", zap.Error(saveErr))
+		}
+		return
+	}
+
+	if policy.HigherOrderRatio > 0 {
+		if saveQuotErr := utils.SaveFile(utils.ResultPath(modelName, "quotations.txt"), false, analyzer.ReportQuotations); saveQuotErr != nil {
+			logger.Error("Failed to save the quotation statistics", zap.Error(saveQuotErr))
+		}
+	}
+
+	if !result.Identical {
+		fmt.Printf("Send:	 %s\n", candidate.Message)
+		fmt.Printf("Received: %s\n", response)
+		fmt.Printf("Compliance: %s\n\n", result.Compliance)
 
-			if saveErr := utils.SaveResult(*modelName, candidate, response); saveErr != nil {
-				logger.Error("Failed to save the discrepancies", zap.Error(saveErr))
+		if saveErr := utils.SaveFile(utils.ResultPath(modelName, "all.txt"), true, reportObservation(result.Observation, effectiveSampling)); saveErr != nil {
+			logger.Error("Failed to save the discrepancies", zap.Error(saveErr))
+		}
+
+		if verdicts := analyzer.Verdicts(); len(verdicts) > 0 {
+			if saveDelimErr := utils.SaveDelimiters(modelName, verdicts); saveDelimErr != nil {
+				logger.Error("Failed to save the delimiters", zap.Error(saveDelimErr))
+			}
+			report := func(w io.Writer) error { return gen.ReportProvenance(w, verdicts) }
+			if saveProvErr := utils.SaveFile(utils.ResultPath(modelName, "provenance.txt"), false, report); saveProvErr != nil {
+				logger.Error("Failed to save the provenance of the delimiters", zap.Error(saveProvErr))
 			}
+		}
+	}
+}
+
+// runAblation replays the same candidates under each echo instruction variant and reports
+// the echo fidelity and delimiter swallow rates of each variant
+// reportObservation formats the observation and the sampling that produced it for the observation log
+func reportObservation(observation analyzer.Observation, sampling client.Sampling) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		candidate := observation.Candidate
+
+		var delimiters, expressions, mentions []string
+		for _, item := range candidate.Items {
+			switch item.Type {
+			case generator.Delimiter:
+				delimiters = append(delimiters, item.Token)
+			case generator.Expression:
+				expressions = append(expressions, item.Token)
+			case generator.HigherOrder:
+				mentions = append(mentions, fmt.Sprintf("%s (depth %d)", item.Delimiter, item.Depth))
+			}
+		}
+
+		var probe string
+		if candidate.Canary != "" {
+			probe = fmt.Sprintf("Role probe: %s (canary %s)\n", candidate.Probe, candidate.Canary)
+		}
+
+		var history strings.Builder
+		for _, turn := range candidate.History {
+			history.WriteString(fmt.Sprintf("History (%s): %s\n", turn.Role, turn.Message))
+		}
+
+		// the raw forms are kept along with the normalized forms actually compared, for audit
+		var normalized string
+		if observation.NormalizedMessage != candidate.Message || observation.NormalizedEcho != observation.Raw {
+			normalized = fmt.Sprintf("Sent (normalized)	: %s\nReceived (normalized): %s\n", observation.NormalizedMessage, observation.NormalizedEcho)
+		}
+
+		_, err := fmt.Fprintf(
+			w,
+			"%s%sSampling: %s\nFormat: %s\nSent	: %s\nReceived: %s\n%sCompliance: %s\nDelimiters: %v\nExpressions: %v\nMentions: %v\n\n",
+			probe, history.String(), sampling, candidate.Format, candidate.Message, observation.Raw, normalized,
+			observation.Compliance, delimiters, expressions, mentions,
+		)
+		return err
+	}
+}
+
+func runAblation(logger *zap.Logger, gen *generator.Generator, cl *client.Client, policy generator.GenerationPolicy,
+	modelName string, candidatesCount int, analysis experiment.Analysis) {
+	candidates := generateCandidates(gen, policy, candidatesCount)
+
+	variants := client.EchoVariants()
+	log.Printf("deLLMiter ablation started: %d candidates, %d variants.\n", len(candidates), len(variants))
 
-			if len(mismatchedDelimiters) > 0 {
-				if saveDelimErr := utils.SaveDelimiters(*modelName, mismatchedDelimiters); saveDelimErr != nil {
-					logger.Error("Failed to save LLM delimiters", zap.Error(saveDelimErr))
-				}
+	results, err := experiment.Ablation(candidates, variants, analysis, func(strategy client.PromptStrategy) experiment.QueryFunc {
+		return func(candidate generator.Candidate) (string, error) {
+			response, err := cl.QueryWithStrategy(strategy, modelName, historyMessages(candidate), candidate.Message)
+			if err != nil {
+				logger.Error("Failed to query the model", zap.Error(err))
 			}
+			return response, err
 		}
+	})
+	if err != nil {
+		logger.Fatal("Failed to run the ablation", zap.Error(err))
+	}
+
+	report := func(w io.Writer) error { return experiment.ReportAblation(w, results) }
+	if err := utils.SaveFile(utils.ResultPath(modelName, "ablation.txt"), false, report); err != nil {
+		logger.Error("Failed to save the ablation report", zap.Error(err))
+	}
+
+	if best, ok := experiment.Best(results); ok {
+		fmt.Printf("Least noisy echo instruction: %s (fidelity %.0f%%, swallow rate %.0f%%)\n",
+			best.Label, 100*best.Fidelity(), 100*best.SwallowRate())
+	}
+}
+
+// runSweep replays the same candidates at each temperature and reports the swallow rate
+// of each delimiter versus the temperature
+func runSweep(logger *zap.Logger, gen *generator.Generator, cl *client.Client, policy generator.GenerationPolicy,
+	modelName string, candidatesCount int, sampling client.Sampling, temperatures []float64, analysis experiment.Analysis) {
+	candidates := generateCandidates(gen, policy, candidatesCount)
+
+	log.Printf("deLLMiter temperature sweep started: %d candidates, %d temperatures.\n", len(candidates), len(temperatures))
+
+	results, err := experiment.Sweep(candidates, temperatures, analysis, func(temperature float64) experiment.QueryFunc {
+		pointSampling := sampling
+		pointSampling.Temperature = &temperature
+		pointClient := cl.WithSampling(pointSampling)
+
+		return func(candidate generator.Candidate) (string, error) {
+			response, err := pointClient.QueryConversation(modelName, historyMessages(candidate), candidate.Message)
+			if err != nil {
+				logger.Error("Failed to query the model", zap.Error(err))
+			}
+			return response, err
+		}
+	})
+	if err != nil {
+		logger.Fatal("Failed to run the temperature sweep", zap.Error(err))
+	}
+
+	report := func(w io.Writer) error { return experiment.ReportSweep(w, results) }
+	if err := utils.SaveFile(utils.ResultPath(modelName, "sweep.txt"), false, report); err != nil {
+		logger.Error("Failed to save the sweep report", zap.Error(err))
+	}
+}
+
+// delimiterSources selects where the delimiters of the candidates come from
+type delimiterSources struct {
+	// tokenizerDir holds Hugging Face tokenizer files whose special tokens are used
+	tokenizerDir string
+	// withKnownDelimiters merges the special tokens of the tokenizer files into the known delimiters
+	withKnownDelimiters bool
+	catalogDir          string
+	// families are the catalog families used (detected from the model name if empty)
+	families  []string
+	modelName string
+}
+
+// newGenerator creates the generator from the special tokens of the tokenizer files, if any,
+// or else from the known delimiters
+func newGenerator(logger *zap.Logger, sources delimiterSources) (*generator.Generator, error) {
+	if sources.tokenizerDir == "" {
+		return newKnownGenerator(logger, sources)
+	}
+
+	delimiters, err := tokenizer.Import(sources.tokenizerDir)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, delimiter := range delimiters {
+		counts[delimiter.Source]++
+	}
+	for source, count := range counts {
+		logger.Info("Imported special tokens", zap.String("source", source), zap.Int("count", count))
+	}
+
+	if !sources.withKnownDelimiters {
+		return generator.NewGeneratorFromDelimiters(logger, delimiters)
+	}
+
+	gen, err := newKnownGenerator(logger, sources)
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("Merged special tokens into the known delimiters", zap.Int("added", gen.AddDelimiters(delimiters)))
+	return gen, nil
+}
+
+// newKnownGenerator creates the generator from the families of the delimiter catalog, or from
+// the known delimiters file when there is no catalog
+func newKnownGenerator(logger *zap.Logger, sources delimiterSources) (*generator.Generator, error) {
+	if info, err := os.Stat(sources.catalogDir); err != nil || !info.IsDir() {
+		logger.Info("No delimiter catalog found, using the known delimiters file", zap.String("catalog", sources.catalogDir))
+		return generator.NewGenerator(logger)
+	}
+
+	catalog, err := generator.LoadCatalog(sources.catalogDir)
+	if err != nil {
+		return nil, err
+	}
+
+	families := sources.families
+	if len(families) == 0 {
+		families = generator.DetectFamilies(sources.modelName)
+	}
+	if len(families) == 0 {
+		logger.Info("No delimiter family detected, using the whole catalog", zap.String("model", sources.modelName))
+	} else {
+		logger.Info("Delimiter families selected", zap.Strings("families", families))
+	}
+
+	return generator.NewGeneratorFromCatalog(logger, catalog, families)
+}
+
+// catalogDelimiters returns all the delimiters of the catalog, if any
+func catalogDelimiters(catalogDir string) []string {
+	catalog, err := generator.LoadCatalog(catalogDir)
+	if err != nil {
+		return nil
+	}
+	delimiters, err := catalog.Resolve(nil)
+	if err != nil {
+		return nil
+	}
+	tokens := make([]string, 0, len(delimiters))
+	for _, delimiter := range delimiters {
+		tokens = append(tokens, delimiter.Token)
+	}
+	return tokens
+}
+
+// generateCandidates generates the set of candidates replayed by the ablation and sweep modes
+func generateCandidates(gen *generator.Generator, policy generator.GenerationPolicy, count int) []generator.Candidate {
+	candidates := make([]generator.Candidate, 0, count)
+	for i := 0; i < count; i++ {
+		candidates = append(candidates, gen.Generate(policy))
+	}
+	return candidates
+}
+
+// stringsFlag collects the values of a repeatable flag
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// historyMessages converts the conversation history of a candidate into client messages
+func historyMessages(candidate generator.Candidate) []client.Message {
+	history := make([]client.Message, 0, len(candidate.History))
+	for _, turn := range candidate.History {
+		history = append(history, client.Message{Role: string(turn.Role), Content: turn.Message})
 	}
+	return history
 }
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/glethuillier/deLLMiter/chattemplate"
+	"github.com/glethuillier/deLLMiter/client"
+	"github.com/glethuillier/deLLMiter/generator"
+	"github.com/glethuillier/deLLMiter/gguf"
+
+	"go.uber.org/zap"
+)
+
+// runRender implements the `render` subcommand: it renders generated candidates through a chat template,
+// offline, to show the exact raw prompt a model would receive and the delimiters colliding with the template
+func runRender(args []string) error {
+	flags := flag.NewFlagSet("render", flag.ExitOnError)
+	templateFile := flags.String("template", "", "A Jinja chat template file (required unless -gguf is set).")
+	ggufFile := flags.String("gguf", "", "A GGUF file whose chat template and bos/eos tokens are used (optional).")
+	modelName := flags.String("model", "", "The model name used to select the prompt strategy (optional).")
+	strategiesFile := flags.String("strategies", "", "A JSON file describing the prompt strategies per model (optional).")
+	raw := flags.Bool("raw", false, "Render the candidate alone, without the echo instructions of the prompt strategy (optional).")
+	policyFile := flags.String("policy", "", "A JSON file describing the candidate generation policy (optional).")
+	tokenizerDir := flags.String("tokenizer", "", "A directory holding Hugging Face tokenizer files whose special tokens are used as delimiters (optional).")
+	catalogDir := flags.String("catalog", generator.CatalogDir, "The delimiter catalog directory, holding one file per model family (optional).")
+	families := flags.String("families", "", "Comma-separated delimiter families of the catalog, detected from the model name if not set (optional).")
+	count := flags.Int("count", 1, "The number of candidates to render (optional).")
+	seed := flags.Int64("seed", 0, "The seed of the candidate generator, for reproducible renderings (optional).")
+	bosToken := flags.String("bosToken", "", "The bos_token variable of the template (optional).")
+	eosToken := flags.String("eosToken", "", "The eos_token variable of the template (optional).")
+	addGenerationPrompt := flags.Bool("addGenerationPrompt", true, "The add_generation_prompt variable of the template (optional).")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s render [flags]\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	options := chattemplate.Options{BOSToken: *bosToken, EOSToken: *eosToken, AddGenerationPrompt: *addGenerationPrompt}
+
+	var template *chattemplate.Template
+	switch {
+	case *templateFile != "":
+		var err error
+		if template, err = chattemplate.ParseFile(*templateFile); err != nil {
+			return err
+		}
+	case *ggufFile != "":
+		var err error
+		if template, err = ggufTemplate(*ggufFile, &options); err != nil {
+			return err
+		}
+	default:
+		flags.Usage()
+		return fmt.Errorf("a chat template is required")
+	}
+
+	policy := generator.DefaultGenerationPolicy()
+	if *policyFile != "" {
+		var err error
+		if policy, err = generator.LoadGenerationPolicy(*policyFile); err != nil {
+			return err
+		}
+	}
+
+	strategies := client.DefaultStrategyRegistry()
+	if *strategiesFile != "" {
+		var err error
+		if strategies, err = client.LoadStrategyRegistry(*strategiesFile); err != nil {
+			return err
+		}
+	}
+	_, strategy := strategies.Lookup(*modelName)
+
+	if *seed != 0 {
+		generator.Seed(*seed)
+	}
+	gen, err := newGenerator(zap.NewNop(), delimiterSources{
+		tokenizerDir: *tokenizerDir,
+		catalogDir:   *catalogDir,
+		families:     generator.ParseFamilies(*families),
+		modelName:    *modelName,
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < *count; i++ {
+		candidate := gen.Generate(policy)
+
+		var messages []client.Message
+		if *raw {
+			messages = append(historyMessages(candidate), client.Message{Role: "user", Content: candidate.Message})
+		} else {
+			messages = strategy.Build(*modelName, historyMessages(candidate), candidate.Message).Messages
+		}
+
+		rendered, err := template.RenderMessages(messages, options)
+		if err != nil {
+			return err
+		}
+		collisions, err := template.Collisions(messages, options, candidateDelimiters(candidate))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Candidate: %s\n", candidate.Message)
+		fmt.Printf("Collisions: %s\n", strings.Join(collisions, " "))
+		fmt.Printf("Prompt:\n%s\n\n", rendered)
+	}
+
+	return nil
+}
+
+// ggufTemplate parses the chat template of a GGUF file and takes the bos/eos tokens of the file,
+// unless set on the command line
+func ggufTemplate(filePath string, options *chattemplate.Options) (*chattemplate.Template, error) {
+	metadata, err := gguf.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	source, ok := metadata.ChatTemplate()
+	if !ok {
+		return nil, fmt.Errorf("no chat template found in the GGUF metadata")
+	}
+
+	if tokens, err := metadata.SpecialTokens(); err == nil {
+		for _, token := range tokens {
+			for _, role := range token.Roles {
+				switch {
+				case role == "bos" && options.BOSToken == "":
+					options.BOSToken = token.Text
+				case role == "eos" && options.EOSToken == "":
+					options.EOSToken = token.Text
+				}
+			}
+		}
+	}
+
+	return chattemplate.Parse(source)
+}
+
+// candidateDelimiters lists the delimiters of a candidate and of its conversation history
+func candidateDelimiters(candidate generator.Candidate) []string {
+	var delimiters []string
+	for _, item := range candidate.Items {
+		switch item.Type {
+		case generator.Delimiter:
+			delimiters = append(delimiters, item.Token)
+		case generator.HigherOrder:
+			delimiters = append(delimiters, item.Delimiter)
+		}
+	}
+	for _, turn := range candidate.History {
+		delimiters = append(delimiters, turn.Delimiters()...)
+	}
+	return delimiters
+}
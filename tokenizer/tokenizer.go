@@ -0,0 +1,189 @@
+package tokenizer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/glethuillier/deLLMiter/generator"
+)
+
+const (
+	tokenizerFile         = "tokenizer.json"
+	tokenizerConfigFile   = "tokenizer_config.json"
+	specialTokensMapFile  = "special_tokens_map.json"
+	additionalSpecialRole = "additional_special"
+)
+
+// roleFields are the fields of tokenizer_config.json and special_tokens_map.json naming a special token
+var roleFields = []string{"bos_token", "eos_token", "pad_token", "unk_token", "sep_token", "cls_token", "mask_token"}
+
+// addedToken is a token of the added_tokens list of tokenizer.json
+// or of the added_tokens_decoder map of tokenizer_config.json
+type addedToken struct {
+	ID      int    `json:"id"`
+	Content string `json:"content"`
+	Special bool   `json:"special"`
+}
+
+// Import reads the special tokens of a Hugging Face model from the tokenizer files found in the directory
+// (tokenizer.json, tokenizer_config.json, special_tokens_map.json). Missing files are skipped, but at least
+// one of them must be present. Each delimiter records the file and the model it comes from.
+func Import(dir string) ([]generator.KnownDelimiter, error) {
+	model := modelName(dir)
+
+	var delimiters []generator.KnownDelimiter
+	found := false
+
+	importers := []struct {
+		file   string
+		decode func(data []byte) ([]generator.KnownDelimiter, error)
+	}{
+		{tokenizerFile, decodeTokenizer},
+		{tokenizerConfigFile, decodeTokenizerConfig},
+		{specialTokensMapFile, decodeSpecialTokensMap},
+	}
+
+	for _, importer := range importers {
+		filePath := filepath.Join(dir, importer.file)
+		data, err := os.ReadFile(filePath)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", filePath, err)
+		}
+		found = true
+
+		imported, err := importer.decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", filePath, err)
+		}
+		for _, delimiter := range imported {
+			delimiter.Source = filePath
+			delimiter.Model = model
+			delimiters = append(delimiters, delimiter)
+		}
+	}
+
+	if !found {
+		return nil, fmt.Errorf("no tokenizer files found in %s", dir)
+	}
+
+	return delimiters, nil
+}
+
+// modelName returns the name of the model from tokenizer_config.json, or else the name of the directory
+func modelName(dir string) string {
+	if data, err := os.ReadFile(filepath.Join(dir, tokenizerConfigFile)); err == nil {
+		var config struct {
+			NameOrPath string `json:"name_or_path"`
+		}
+		if json.Unmarshal(data, &config) == nil && config.NameOrPath != "" {
+			return config.NameOrPath
+		}
+	}
+	return filepath.Base(filepath.Clean(dir))
+}
+
+func decodeTokenizer(data []byte) ([]generator.KnownDelimiter, error) {
+	var tokenizer struct {
+		AddedTokens []addedToken `json:"added_tokens"`
+	}
+	if err := json.Unmarshal(data, &tokenizer); err != nil {
+		return nil, err
+	}
+	return fromAddedTokens(tokenizer.AddedTokens), nil
+}
+
+func decodeTokenizerConfig(data []byte) ([]generator.KnownDelimiter, error) {
+	var config map[string]json.RawMessage
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+
+	var delimiters []generator.KnownDelimiter
+
+	if raw, ok := config["added_tokens_decoder"]; ok {
+		var decoder map[string]addedToken
+		if err := json.Unmarshal(raw, &decoder); err != nil {
+			return nil, fmt.Errorf("invalid added_tokens_decoder: %w", err)
+		}
+		tokens := make([]addedToken, 0, len(decoder))
+		for id, token := range decoder {
+			if _, err := fmt.Sscanf(id, "%d", &token.ID); err != nil {
+				return nil, fmt.Errorf("invalid token id %q: %w", id, err)
+			}
+			tokens = append(tokens, token)
+		}
+		sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID < tokens[j].ID })
+		delimiters = append(delimiters, fromAddedTokens(tokens)...)
+	}
+
+	delimiters = append(delimiters, fromRoleFields(config)...)
+	return delimiters, nil
+}
+
+func decodeSpecialTokensMap(data []byte) ([]generator.KnownDelimiter, error) {
+	var tokensMap map[string]json.RawMessage
+	if err := json.Unmarshal(data, &tokensMap); err != nil {
+		return nil, err
+	}
+	return fromRoleFields(tokensMap), nil
+}
+
+// fromAddedTokens keeps the special added tokens: the other added tokens are ordinary vocabulary
+// (e.g. words or whitespace runs added for efficiency) rather than delimiters
+func fromAddedTokens(tokens []addedToken) []generator.KnownDelimiter {
+	var delimiters []generator.KnownDelimiter
+	for _, token := range tokens {
+		if token.Special {
+			delimiters = append(delimiters, generator.KnownDelimiter{Token: token.Content, Role: "special"})
+		}
+	}
+	return delimiters
+}
+
+// fromRoleFields reads the bos/eos/pad/unk/... fields and the additional_special_tokens list
+func fromRoleFields(fields map[string]json.RawMessage) []generator.KnownDelimiter {
+	var delimiters []generator.KnownDelimiter
+
+	for _, field := range roleFields {
+		if content := tokenContent(fields[field]); content != "" {
+			delimiters = append(delimiters, generator.KnownDelimiter{Token: content, Role: field[:len(field)-len("_token")]})
+		}
+	}
+
+	var additional []json.RawMessage
+	if raw, ok := fields["additional_special_tokens"]; ok && json.Unmarshal(raw, &additional) == nil {
+		for _, raw := range additional {
+			if content := tokenContent(raw); content != "" {
+				delimiters = append(delimiters, generator.KnownDelimiter{Token: content, Role: additionalSpecialRole})
+			}
+		}
+	}
+
+	return delimiters
+}
+
+// tokenContent returns the content of a token serialized either as a string or as an AddedToken object
+func tokenContent(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+
+	var content string
+	if json.Unmarshal(raw, &content) == nil {
+		return content
+	}
+
+	var token addedToken
+	if json.Unmarshal(raw, &token) == nil {
+		return token.Content
+	}
+
+	return ""
+}
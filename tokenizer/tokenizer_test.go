@@ -0,0 +1,122 @@
+package tokenizer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     map[string]string
+		expected  []string
+		roles     []string
+		model     string
+		expectErr bool
+	}{
+		{
+			name: "tokenizer.json special added tokens only",
+			files: map[string]string{
+				tokenizerFile: `{"added_tokens": [
+					{"id": 0, "content": "<s>", "special": true},
+					{"id": 5, "content": "<tool>", "special": false},
+					{"id": 6, "content": "</s>", "special": true}
+				]}`,
+			},
+			expected: []string{"<s>", "</s>"},
+			roles:    []string{"special", "special"},
+		},
+		{
+			name: "tokenizer_config.json decoder and role fields",
+			files: map[string]string{
+				tokenizerConfigFile: `{
+					"name_or_path": "org/model",
+					"added_tokens_decoder": {
+						"32001": {"content": "<|im_end|>", "special": true},
+						"32000": {"content": "<|im_start|>", "special": true}
+					},
+					"bos_token": "<s>",
+					"eos_token": {"content": "</s>", "lstrip": false},
+					"pad_token": null
+				}`,
+			},
+			expected: []string{"<|im_start|>", "<|im_end|>", "<s>", "</s>"},
+			roles:    []string{"special", "special", "bos", "eos"},
+			model:    "org/model",
+		},
+		{
+			name: "special_tokens_map.json",
+			files: map[string]string{
+				specialTokensMapFile: `{
+					"unk_token": "<unk>",
+					"additional_special_tokens": ["<|user|>", {"content": "<|end|>"}]
+				}`,
+			},
+			expected: []string{"<unk>", "<|user|>", "<|end|>"},
+			roles:    []string{"unk", additionalSpecialRole, additionalSpecialRole},
+		},
+		{
+			name:      "no tokenizer files",
+			files:     map[string]string{},
+			expectErr: true,
+		},
+		{
+			name:      "invalid JSON",
+			files:     map[string]string{tokenizerFile: `{`},
+			expectErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := writeFiles(t, tc.files)
+
+			delimiters, err := Import(dir)
+			if tc.expectErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var tokens, roles []string
+			for _, delimiter := range delimiters {
+				tokens = append(tokens, delimiter.Token)
+				roles = append(roles, delimiter.Role)
+
+				if filepath.Dir(delimiter.Source) != dir {
+					t.Errorf("expected source in %s, got %s", dir, delimiter.Source)
+				}
+				model := tc.model
+				if model == "" {
+					model = filepath.Base(dir)
+				}
+				if delimiter.Model != model {
+					t.Errorf("expected model %s, got %s", model, delimiter.Model)
+				}
+			}
+
+			if !reflect.DeepEqual(tokens, tc.expected) {
+				t.Errorf("expected tokens %q, got %q", tc.expected, tokens)
+			}
+			if !reflect.DeepEqual(roles, tc.roles) {
+				t.Errorf("expected roles %q, got %q", tc.roles, roles)
+			}
+		})
+	}
+}
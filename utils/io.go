@@ -3,48 +3,50 @@ package utils
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
-
-	"github.com/glethuillier/deLLMiter/generator"
 )
 
 const resultDir = "./results"
 
-func SaveResult(modelName string, candidate generator.Candidate, response string) error {
-	if err := os.MkdirAll(resultDir, 0755); err != nil {
+// ResultPath returns the path of a result file of the model, e.g. ./results/{model_name}_positions.txt
+func ResultPath(modelName, name string) string {
+	return filepath.Join(resultDir, fmt.Sprintf("%s_%s", modelName, name))
+}
+
+// SaveFile writes a result file with the given function, appending to the file or replacing it. A replaced
+// file is written aside then renamed, so that an interrupted write never corrupts the previous version.
+func SaveFile(fileName string, appendMode bool, write func(w io.Writer) error) error {
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
 		return fmt.Errorf("failed to create results directory: %w", err)
 	}
 
-	fileName := filepath.Join(resultDir, fmt.Sprintf("%s_all.txt", modelName))
-	file, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	target, flag := fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY
+	if !appendMode {
+		target, flag = fileName+".tmp", os.O_TRUNC|os.O_CREATE|os.O_WRONLY
+	}
+
+	file, err := os.OpenFile(target, flag, 0644)
 	if err != nil {
-		return fmt.Errorf("failed to open file %s: %w", fileName, err)
+		return fmt.Errorf("failed to open file %s: %w", target, err)
 	}
-	defer func() {
+	if err := write(file); err != nil {
 		if cerr := file.Close(); cerr != nil {
 			fmt.Printf("warning: failed to close file: %v\n", cerr)
 		}
-	}()
-
-	var delimiters, expressions []string
-	for _, item := range candidate.Items {
-		switch item.Type {
-		case "delimiter":
-			delimiters = append(delimiters, item.Token)
-		case "expression":
-			expressions = append(expressions, item.Token)
-		}
+		return fmt.Errorf("failed to write file %s: %w", target, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to close file %s: %w", target, err)
 	}
 
-	logEntry := fmt.Sprintf(
-		"Sent	: %s\nReceived: %s\nDelimiters: %v\nExpressions: %v\n\n",
-		candidate.Message, response, delimiters, expressions,
-	)
-	if _, writeErr := file.WriteString(logEntry); err != nil {
-		return fmt.Errorf("failed to write log entry to file: %w", writeErr)
+	if !appendMode {
+		if err := os.Rename(target, fileName); err != nil {
+			return fmt.Errorf("failed to replace file %s: %w", fileName, err)
+		}
 	}
 
 	return nil
@@ -64,7 +66,7 @@ func SaveDelimiters(modelName string, delimiters []string) error {
 		return fmt.Errorf("failed to create results directory: %w", err)
 	}
 
-	fileName := filepath.Join(resultDir, fmt.Sprintf("%s_delimiters.txt", modelName))
+	fileName := DelimitersFilePath(modelName)
 	existingDelimiters := make(map[string]struct{})
 	if file, err := os.Open(fileName); err == nil {
 		defer func() {
@@ -116,3 +118,39 @@ func SaveDelimiters(modelName string, delimiters []string) error {
 
 	return nil
 }
+
+// DelimitersFilePath returns the path of the file holding the delimiters detected for the model
+func DelimitersFilePath(modelName string) string {
+	return filepath.Join(resultDir, fmt.Sprintf("%s_delimiters.txt", modelName))
+}
+
+// LoadDelimiters reads a delimiters file (one delimiter per line, as written by SaveDelimiters)
+func LoadDelimiters(filePath string) ([]string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open delimiters file: %w", err)
+	}
+	defer func() {
+		if cerr := file.Close(); cerr != nil {
+			fmt.Printf("warning: failed to close file: %v\n", cerr)
+		}
+	}()
+
+	var delimiters []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if text := strings.TrimSpace(scanner.Text()); text != "" {
+			delimiters = append(delimiters, text)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading delimiters file: %w", err)
+	}
+
+	return delimiters, nil
+}
+
+// StateFilePath returns the path of the file holding the persisted analyzer state of the model
+func StateFilePath(modelName string) string {
+	return filepath.Join(resultDir, fmt.Sprintf("%s_state.json", modelName))
+}